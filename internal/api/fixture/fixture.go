@@ -0,0 +1,212 @@
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+)
+
+// fileTimeFormat names each recording by when it was made.
+const fileTimeFormat = "20060102T150405.000"
+
+// Recording is one recorded API response. Recordings are kept in a fixture
+// directory as <dir>/<url key>/<time>.json, or <time>-<n>.json when more than
+// one is recorded within the same millisecond.
+type Recording struct {
+	URL    string          `json:"url"`
+	Time   time.Time       `json:"time"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"` // a body that is not JSON
+}
+
+// Key names the directory holding the recordings of a URL.
+func Key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Recorder is an http.RoundTripper that saves every response it passes on
+// into a fixture directory. Request headers, and so credentials, are not saved.
+type Recorder struct {
+	dir    string
+	next   http.RoundTripper
+	clock  clock.Clock
+	logger *logrus.Logger
+}
+
+// NewRecorder records the responses of requests made through next into dir.
+func NewRecorder(dir string, next http.RoundTripper, clk clock.Clock, logger *logrus.Logger) *Recorder {
+	return &Recorder{
+		dir:    dir,
+		next:   next,
+		clock:  clk,
+		logger: logger,
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := Recording{
+		URL:    req.URL.String(),
+		Time:   r.clock.Now(),
+		Status: resp.StatusCode,
+	}
+	if json.Valid(body) {
+		rec.Body = body
+	} else {
+		rec.Text = string(body)
+	}
+	if err := r.save(rec); err != nil {
+		r.logger.WithFields(logrus.Fields{
+			"url":   rec.URL,
+			"error": err,
+		}).Warn("failed to record response")
+	}
+	return resp, nil
+}
+
+func (r *Recorder) save(rec Recording) error {
+	dir := filepath.Join(r.dir, Key(rec.URL))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating fixture directory: %w", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding recording: %w", err)
+	}
+	name := rec.Time.Format(fileTimeFormat)
+	for n := 1; ; n++ {
+		file, err := os.OpenFile(filepath.Join(dir, name+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			name = fmt.Sprintf("%s-%d", rec.Time.Format(fileTimeFormat), n)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("creating recording: %w", err)
+		}
+		_, err = file.Write(append(data, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("writing recording: %w", err)
+		}
+		return nil
+	}
+}
+
+// Replayer is an http.RoundTripper that answers requests from a fixture
+// directory instead of the network. Each request gets the latest response
+// recorded for its URL at or before the clock's time. A request for a URL
+// that was not recorded by then fails, as the response is not known yet.
+type Replayer struct {
+	clock      clock.Clock
+	recordings map[string][]Recording // by URL, oldest first
+}
+
+// Load reads every recording in a fixture directory.
+func Load(dir string, clk clock.Clock) (*Replayer, error) {
+	r := &Replayer{
+		clock:      clk,
+		recordings: make(map[string][]Recording),
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		r.recordings[rec.URL] = append(r.recordings[rec.URL], rec)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+	for _, recs := range r.recordings {
+		sort.Slice(recs, func(i, j int) bool {
+			return recs[i].Time.Before(recs[j].Time)
+		})
+	}
+	return r, nil
+}
+
+// First returns the time of the earliest recording.
+func (r *Replayer) First() (time.Time, bool) {
+	var first time.Time
+	for _, recs := range r.recordings {
+		if first.IsZero() || recs[0].Time.Before(first) {
+			first = recs[0].Time
+		}
+	}
+	return first, !first.IsZero()
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	recs := r.recordings[req.URL.String()]
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("no recording of %s", req.URL)
+	}
+	now := r.clock.Now()
+	if recs[0].Time.After(now) {
+		return nil, fmt.Errorf("no recording of %s at or before %s, the first is at %s",
+			req.URL, now.Format(time.TimeOnly), recs[0].Time.Format(time.TimeOnly))
+	}
+	rec := recs[0]
+	for _, candidate := range recs[1:] {
+		if candidate.Time.After(now) {
+			break
+		}
+		rec = candidate
+	}
+
+	body := []byte(rec.Text)
+	if len(rec.Body) > 0 {
+		body = rec.Body
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
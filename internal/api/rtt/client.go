@@ -5,31 +5,51 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
-const baseURL = "https://api.rtt.io/api/v1"
+// DefaultBaseURL is the RealTimeTrains API.
+const DefaultBaseURL = "https://api.rtt.io/api/v1"
+
+// API is the part of the RealTimeTrains API trainpal uses.
+type API interface {
+	Search(ctx context.Context, from, to string, t time.Time) (*SearchResponse, error)
+	GetService(ctx context.Context, serviceUid string, runDate time.Time) (*ServiceDetailResponse, error)
+}
 
 // Client is a RealTimeTrains API client.
 type Client struct {
 	httpClient *http.Client
+	baseURL    string
 	username   string
 	password   string
 }
 
-// NewClient creates a new RTT client.
-func NewClient(username, password string) *Client {
+// NewClient creates a new RTT client for the API at baseURL, or
+// DefaultBaseURL if it is empty.
+func NewClient(baseURL, username, password string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
 	return &Client{
 		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
 		username:   username,
 		password:   password,
 	}
 }
 
+// SetTransport sends the client's requests through rt, e.g. to record or
+// replay them.
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
 // Search finds services between two stations at a specific time.
 func (c *Client) Search(ctx context.Context, from, to string, t time.Time) (*SearchResponse, error) {
 	url := fmt.Sprintf("%s/json/search/%s/to/%s/%04d/%02d/%02d/%02d%02d",
-		baseURL, from, to,
+		c.baseURL, from, to,
 		t.Year(), int(t.Month()), t.Day(),
 		t.Hour(), t.Minute())
 
@@ -60,7 +80,7 @@ func (c *Client) Search(ctx context.Context, from, to string, t time.Time) (*Sea
 // GetService retrieves detailed information about a specific service.
 func (c *Client) GetService(ctx context.Context, serviceUid string, runDate time.Time) (*ServiceDetailResponse, error) {
 	url := fmt.Sprintf("%s/json/service/%s/%04d/%02d/%02d",
-		baseURL, serviceUid,
+		c.baseURL, serviceUid,
 		runDate.Year(), int(runDate.Month()), runDate.Day())
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
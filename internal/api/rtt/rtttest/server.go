@@ -0,0 +1,266 @@
+// Package rtttest provides a fake RealTimeTrains API server, so the monitors
+// and scheduler can run offline against scripted services.
+//
+//	srv := rtttest.NewServer()
+//	defer srv.Close()
+//	srv.Add(rtttest.Service{
+//		UID: "W12345", From: "WIN", To: "WAT",
+//		Departure: dep, Arrival: dep.Add(time.Hour),
+//		Timeline: []rtttest.Update{
+//			rtttest.Delayed(dep.Add(-30*time.Minute), 10),
+//			rtttest.Departed(dep.Add(10*time.Minute), 10),
+//			rtttest.Arrived(dep.Add(70*time.Minute), 10),
+//		},
+//	})
+//	client := rtt.NewClient(srv.URL, "", "")
+package rtttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+)
+
+const (
+	dateFormat = "2006-01-02"
+	timeFormat = "1504"
+)
+
+// searchWindow is how far ahead of the requested time a search returns services.
+const searchWindow = 2 * time.Hour
+
+// Service is a scripted service between two stations.
+type Service struct {
+	UID       string
+	Operator  string
+	From      string
+	To        string
+	Departure time.Time // booked departure from From
+	Arrival   time.Time // booked arrival at To
+	Platform  string    // booked platform at From
+	Timeline  []Update  // in order of At
+}
+
+// Update is the state of a service from At until the next update. Before its
+// first update a service is running on time.
+type Update struct {
+	At        time.Time
+	Delay     int    // minutes late
+	Platform  string // confirmed platform at From, if set
+	Departed  bool
+	Arrived   bool
+	Cancelled string // cancellation reason; set to cancel the service
+}
+
+// OnTime is a service running on time from at.
+func OnTime(at time.Time) Update {
+	return Update{At: at}
+}
+
+// Delayed is a service running minutes late from at.
+func Delayed(at time.Time, minutes int) Update {
+	return Update{At: at, Delay: minutes}
+}
+
+// Departed is a service leaving From minutes late at at.
+func Departed(at time.Time, minutes int) Update {
+	return Update{At: at, Delay: minutes, Departed: true}
+}
+
+// Arrived is a service reaching To minutes late at at.
+func Arrived(at time.Time, minutes int) Update {
+	return Update{At: at, Delay: minutes, Departed: true, Arrived: true}
+}
+
+// Cancelled is a service cancelled at at.
+func Cancelled(at time.Time, reason string) Update {
+	return Update{At: at, Cancelled: reason}
+}
+
+// Server is a fake RTT API serving the state of its services at the current
+// time, as given by the function set with SetNow.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	now      func() time.Time
+	services []Service
+}
+
+// NewServer starts a fake RTT API. Close it when done.
+func NewServer() *Server {
+	s := &Server{now: time.Now}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /json/search/{from}/to/{to}/{year}/{month}/{day}/{time}", s.search)
+	mux.HandleFunc("GET /json/service/{uid}/{year}/{month}/{day}", s.service)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetNow sets the clock used to pick each service's current state.
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Add adds a service.
+func (s *Server) Add(svc Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = append(s.services, svc)
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	from, to := r.PathValue("from"), r.PathValue("to")
+	at, err := time.ParseInLocation(dateFormat+" "+timeFormat,
+		r.PathValue("year")+"-"+r.PathValue("month")+"-"+r.PathValue("day")+" "+r.PathValue("time"), time.Local)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	var matched []Service
+	for _, svc := range s.services {
+		if svc.From == from && svc.To == to && !svc.Departure.Before(at) && svc.Departure.Before(at.Add(searchWindow)) {
+			matched = append(matched, svc)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Departure.Before(matched[j].Departure)
+	})
+	resp := rtt.SearchResponse{
+		Location:          rtt.Location{Name: from, CRS: from},
+		Filter:            rtt.Location{Name: to, CRS: to},
+		Services:          []rtt.Service{},
+		RealtimeAvailable: true,
+	}
+	for _, svc := range matched {
+		resp.Services = append(resp.Services, svc.search(now))
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) service(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	date := r.PathValue("year") + "-" + r.PathValue("month") + "-" + r.PathValue("day")
+
+	s.mu.Lock()
+	now := s.now()
+	var found *Service
+	for i, svc := range s.services {
+		if svc.UID == uid && svc.Departure.Format(dateFormat) == date {
+			found = &s.services[i]
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, found.detail(now))
+}
+
+// state returns the service's latest update at now.
+func (svc Service) state(now time.Time) Update {
+	var state Update
+	for _, u := range svc.Timeline {
+		if u.At.After(now) {
+			break
+		}
+		state = u
+	}
+	return state
+}
+
+// origin returns the service's details at From at now.
+func (svc Service) origin(now time.Time) rtt.LocationDetail {
+	state := svc.state(now)
+	delay := time.Duration(state.Delay) * time.Minute
+	detail := rtt.LocationDetail{
+		RealtimeActivated:       true,
+		Origin:                  []rtt.LocationName{{Description: svc.From, CRS: svc.From, PublicTime: svc.Departure.Format(timeFormat)}},
+		Destination:             []rtt.LocationName{{Description: svc.To, CRS: svc.To, PublicTime: svc.Arrival.Format(timeFormat)}},
+		GbttBookedDeparture:     svc.Departure.Format(timeFormat),
+		RealtimeDeparture:       svc.Departure.Add(delay).Format(timeFormat),
+		RealtimeDepartureActual: state.Departed,
+		Platform:                svc.Platform,
+	}
+	if state.Platform != "" {
+		detail.Platform = state.Platform
+		detail.PlatformConfirmed = true
+		detail.PlatformChanged = svc.Platform != "" && state.Platform != svc.Platform
+	}
+	if state.Cancelled != "" {
+		detail.DisplayAs = "CANCELLED_CALL"
+		detail.CancelReasonShortText = state.Cancelled
+		detail.CancelReasonLongText = state.Cancelled
+	}
+	return detail
+}
+
+func (svc Service) search(now time.Time) rtt.Service {
+	return rtt.Service{
+		ServiceUid:     svc.UID,
+		RunDate:        svc.Departure.Format(dateFormat),
+		ServiceType:    "train",
+		AtocName:       svc.Operator,
+		LocationDetail: svc.origin(now),
+	}
+}
+
+func (svc Service) detail(now time.Time) rtt.ServiceDetailResponse {
+	state := svc.state(now)
+	origin := svc.origin(now)
+	delay := time.Duration(state.Delay) * time.Minute
+	return rtt.ServiceDetailResponse{
+		ServiceUid:  svc.UID,
+		RunDate:     svc.Departure.Format(dateFormat),
+		ServiceType: "train",
+		AtocName:    svc.Operator,
+		Origin:      origin.Origin,
+		Destination: origin.Destination,
+		Locations: []rtt.ServiceLocation{
+			{
+				RealtimeActivated:       true,
+				CRS:                     svc.From,
+				Description:             svc.From,
+				GbttBookedDeparture:     origin.GbttBookedDeparture,
+				RealtimeDeparture:       origin.RealtimeDeparture,
+				RealtimeDepartureActual: origin.RealtimeDepartureActual,
+				Platform:                origin.Platform,
+				PlatformConfirmed:       origin.PlatformConfirmed,
+				PlatformChanged:         origin.PlatformChanged,
+				DisplayAs:               origin.DisplayAs,
+				CancelReasonShortText:   origin.CancelReasonShortText,
+				CancelReasonLongText:    origin.CancelReasonLongText,
+			},
+			{
+				RealtimeActivated:     true,
+				CRS:                   svc.To,
+				Description:           svc.To,
+				GbttBookedArrival:     svc.Arrival.Format(timeFormat),
+				RealtimeArrival:       svc.Arrival.Add(delay).Format(timeFormat),
+				RealtimeArrivalActual: state.Arrived,
+				DisplayAs:             origin.DisplayAs,
+				CancelReasonShortText: origin.CancelReasonShortText,
+				CancelReasonLongText:  origin.CancelReasonLongText,
+			},
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -22,6 +22,12 @@ func NewClient() *Client {
 	}
 }
 
+// SetTransport sends the client's requests through rt, e.g. to record or
+// replay them.
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
 // GetNorthernLineStatus retrieves the current status of the Northern Line.
 func (c *Client) GetNorthernLineStatus(ctx context.Context) (*LineStatus, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, northernLineStatusURL, nil)
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/monitor"
+	"github.com/danpilch/trainpal/internal/notify"
+)
+
+// Bot answers commands sent from a chat. /status only covers the journeys the
+// chat's recipient follows. /mute and /unmute set the chat's notifier's mute
+// in the mutes file, as the mute command does.
+type Bot struct {
+	cfg          *config.Config
+	trainMonitor *monitor.TrainMonitor
+	tubeMonitor  *monitor.TubeMonitor
+	mutesPath    string
+	notifier     string
+	logger       *logrus.Logger
+}
+
+// New creates a bot for the chat of the named notifier, as it is keyed in
+// the mutes file at mutesPath. journeys limits the bot to the named journeys,
+// or to every journey if empty.
+func New(cfg *config.Config, journeys []string, trainMonitor *monitor.TrainMonitor, tubeMonitor *monitor.TubeMonitor, mutesPath, notifier string, logger *logrus.Logger) *Bot {
+	if len(journeys) > 0 {
+		scoped := *cfg
+		scoped.Journeys = nil
+		for _, name := range journeys {
+			if j := cfg.Journey(name); j != nil {
+				scoped.Journeys = append(scoped.Journeys, *j)
+			}
+		}
+		cfg = &scoped
+	}
+	return &Bot{
+		cfg:          cfg,
+		trainMonitor: trainMonitor,
+		tubeMonitor:  tubeMonitor,
+		mutesPath:    mutesPath,
+		notifier:     notifier,
+		logger:       logger,
+	}
+}
+
+const help = `Commands:
+/status [journey] - check the next journey today, or the named journey
+/tube - current Northern line status
+/mute <duration> - hold back normal alerts, e.g. /mute 2h
+/unmute - resume alerts`
+
+// Handle runs a command and returns the reply.
+func (b *Bot) Handle(ctx context.Context, command string, args []string) string {
+	switch command {
+	case "status":
+		return b.status(ctx, args)
+	case "tube":
+		return b.tube(ctx)
+	case "mute":
+		return b.mute(args)
+	case "unmute":
+		if err := b.setMute(time.Time{}); err != nil {
+			return fmt.Sprintf("Failed to unmute: %v", err)
+		}
+		return "Alerts resumed."
+	case "start", "help":
+		return help
+	default:
+		return fmt.Sprintf("Unknown command /%s\n\n%s", command, help)
+	}
+}
+
+func (b *Bot) status(ctx context.Context, args []string) string {
+	var journey *config.TrainConfig
+	if len(args) > 0 {
+		journey = b.cfg.Journey(args[0])
+		if journey == nil {
+			return fmt.Sprintf("No journey named %q.", args[0])
+		}
+	} else {
+		journey = b.cfg.NextJourney(time.Now())
+		if journey == nil {
+			return "No more journeys today."
+		}
+	}
+
+	leg := journey.Route()[0]
+	e, err := b.trainMonitor.CurrentStatus(ctx, journey, leg)
+	if err != nil {
+		b.logger.WithFields(logrus.Fields{
+			"journey": journey.Name,
+			"error":   err,
+		}).Error("status command failed")
+		return fmt.Sprintf("Status check for %s failed: %v", journey.Name, err)
+	}
+	if e == nil {
+		return fmt.Sprintf("No service found for %s (%s to %s at %s).", journey.Name, leg.From, leg.To, leg.Departure)
+	}
+	msg, _ := notify.Format(e)
+	return msg.Title + "\n" + msg.Body
+}
+
+func (b *Bot) tube(ctx context.Context) string {
+	status, reason, err := b.tubeMonitor.CurrentStatus(ctx)
+	if err != nil {
+		b.logger.WithField("error", err).Error("tube command failed")
+		return fmt.Sprintf("Failed to get Northern line status: %v", err)
+	}
+	if reason == "" {
+		return "Northern line: " + status
+	}
+	return fmt.Sprintf("Northern line: %s\n%s", status, strings.TrimSpace(reason))
+}
+
+func (b *Bot) mute(args []string) string {
+	if len(args) == 0 {
+		return "Usage: /mute <duration>, e.g. /mute 2h or /mute 30m"
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d <= 0 {
+		return fmt.Sprintf("Invalid duration %q, e.g. /mute 2h or /mute 30m", args[0])
+	}
+	until := time.Now().Add(d)
+	if err := b.setMute(until); err != nil {
+		return fmt.Sprintf("Failed to mute: %v", err)
+	}
+	return fmt.Sprintf("Alerts muted until %s.", until.Format("15:04"))
+}
+
+// setMute mutes the chat's notifier until the given time, or unmutes it if
+// until is zero.
+func (b *Bot) setMute(until time.Time) error {
+	if b.mutesPath == "" {
+		return fmt.Errorf("no mutes file configured")
+	}
+	if err := notify.MuteNotifier(b.mutesPath, b.notifier, until); err != nil {
+		b.logger.WithFields(logrus.Fields{
+			"notifier": b.notifier,
+			"error":    err,
+		}).Error("mute command failed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs functions after a delay. Code that schedules
+// or checks trains asks a Clock rather than calling time.Now, so a recorded
+// day can be replayed and a simulated day run without waiting.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	// Drift returns how long the machine was suspended between two readings
+	// of Now, during which timers were held up.
+	Drift(from, to time.Time) time.Duration
+}
+
+// Timer is a function waiting to run, as returned by AfterFunc.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Drift returns how far the wall clock ran ahead of the monotonic clock
+// between two readings of Now. The monotonic clock stops while the machine is
+// suspended, so this is about how long it was suspended for. It is zero for
+// times without a monotonic reading.
+func (Real) Drift(from, to time.Time) time.Duration {
+	return to.Round(0).Sub(from.Round(0)) - to.Sub(from)
+}
+
+// Fake is a clock that only moves when it is told to. Functions passed to
+// AfterFunc run as the clock passes their time, in order, on the goroutine
+// moving the clock, so a day of timers runs as fast as the work they do.
+type Fake struct {
+	mu       sync.Mutex
+	now      time.Time
+	timers   []*fakeTimer
+	suspends []suspend
+}
+
+// suspend is a simulated suspend of the machine, for d until resumed.
+type suspend struct {
+	resumed time.Time
+	d       time.Duration
+}
+
+// NewFake creates a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{clock: f, f: fn}
+	f.start(t, d)
+	return t
+}
+
+// Set moves the clock to t, running every timer due by then. Each timer runs
+// with the clock showing the time it was due. A timer may move the clock on
+// itself, past t, in which case Set leaves it there rather than moving it back.
+func (f *Fake) Set(t time.Time) {
+	for {
+		f.mu.Lock()
+		next := f.next(t)
+		if next == nil {
+			if t.After(f.now) {
+				f.now = t
+			}
+			f.mu.Unlock()
+			return
+		}
+		f.stop(next)
+		if next.at.After(f.now) {
+			f.now = next.at
+		}
+		f.mu.Unlock()
+
+		next.f()
+	}
+}
+
+// Advance moves the clock forward by d, running every timer due by then.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Suspend moves the clock forward by d as if the machine was suspended for
+// that long. No timers run, and those waiting run d later than they were due,
+// as real timers do not count time spent suspended. Drift reports the suspend.
+func (f *Fake) Suspend(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, t := range f.timers {
+		t.at = t.at.Add(d)
+	}
+	f.suspends = append(f.suspends, suspend{resumed: f.now, d: d})
+}
+
+// Drift returns the total time suspended by Suspend between from and to.
+func (f *Fake) Drift(from, to time.Time) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var d time.Duration
+	for _, s := range f.suspends {
+		if s.resumed.After(from) && !s.resumed.After(to) {
+			d += s.d
+		}
+	}
+	return d
+}
+
+// next returns the earliest timer due by t, or nil. Timers due at the same
+// time run in the order they were started. The caller must hold f.mu.
+func (f *Fake) next(t time.Time) *fakeTimer {
+	var next *fakeTimer
+	for _, timer := range f.timers {
+		if !timer.at.After(t) && (next == nil || timer.at.Before(next.at)) {
+			next = timer
+		}
+	}
+	return next
+}
+
+// start arms t to run after d. The caller must hold f.mu.
+func (f *Fake) start(t *fakeTimer, d time.Duration) {
+	t.at = f.now.Add(d)
+	f.timers = append(f.timers, t)
+}
+
+// stop disarms t, reporting whether it was armed. The caller must hold f.mu.
+func (f *Fake) stop(t *fakeTimer) bool {
+	for i, timer := range f.timers {
+		if timer == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	f     func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.stop(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.stop(t)
+	t.clock.start(t, d)
+	return active
+}
@@ -0,0 +1,161 @@
+package clock
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC)
+
+func TestFakeRunsTimersInOrder(t *testing.T) {
+	f := NewFake(start)
+	var ran []string
+	at := func(name string) func() {
+		return func() { ran = append(ran, name+" "+f.Now().Format("15:04")) }
+	}
+	f.AfterFunc(30*time.Minute, at("b"))
+	f.AfterFunc(10*time.Minute, at("a"))
+	f.AfterFunc(30*time.Minute, at("c"))
+	f.AfterFunc(2*time.Hour, at("later"))
+
+	f.Set(start.Add(time.Hour))
+
+	want := []string{"a 07:10", "b 07:30", "c 07:30"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if got := f.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now() = %v, want %v", got, start.Add(time.Hour))
+	}
+}
+
+func TestFakeSetReentry(t *testing.T) {
+	f := NewFake(start)
+	var ran []string
+	record := func(name string) {
+		ran = append(ran, name+" "+f.Now().Format("15:04"))
+	}
+
+	// A timer that starts another timer due within the same Set, and one that
+	// moves the clock on past the time being set.
+	f.AfterFunc(5*time.Minute, func() {
+		record("first")
+		f.AfterFunc(5*time.Minute, func() { record("chained") })
+	})
+	f.AfterFunc(20*time.Minute, func() {
+		record("jump")
+		f.Set(start.Add(2 * time.Hour))
+	})
+	f.AfterFunc(90*time.Minute, func() { record("during jump") })
+
+	f.Set(start.Add(30 * time.Minute))
+
+	want := []string{"first 07:05", "chained 07:10", "jump 07:20", "during jump 08:30"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if got := f.Now(); !got.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Now() = %v, want the clock left at %v", got, start.Add(2*time.Hour))
+	}
+}
+
+func TestFakeTimerStopAndReset(t *testing.T) {
+	f := NewFake(start)
+	runs := 0
+	timer := f.AfterFunc(10*time.Minute, func() { runs++ })
+
+	if !timer.Stop() {
+		t.Error("Stop() on an armed timer = false, want true")
+	}
+	if timer.Stop() {
+		t.Error("Stop() on a stopped timer = true, want false")
+	}
+	f.Advance(time.Hour)
+	if runs != 0 {
+		t.Fatalf("stopped timer ran %d times", runs)
+	}
+
+	if timer.Reset(10 * time.Minute) {
+		t.Error("Reset() on a stopped timer = true, want false")
+	}
+	f.Advance(10 * time.Minute)
+	if runs != 1 {
+		t.Fatalf("reset timer ran %d times, want 1", runs)
+	}
+
+	// Resetting a timer that has fired arms it again from the current time.
+	if timer.Reset(5 * time.Minute) {
+		t.Error("Reset() on a fired timer = true, want false")
+	}
+	f.Advance(4 * time.Minute)
+	if runs != 1 {
+		t.Fatalf("timer ran early, %d times", runs)
+	}
+	f.Advance(time.Minute)
+	if runs != 2 {
+		t.Errorf("timer reset after firing ran %d times, want 2", runs)
+	}
+}
+
+func TestFakeTimerResetFromItsFunc(t *testing.T) {
+	f := NewFake(start)
+	var ran []string
+	var timer Timer
+	timer = f.AfterFunc(15*time.Minute, func() {
+		ran = append(ran, f.Now().Format("15:04"))
+		if len(ran) < 3 {
+			timer.Reset(15 * time.Minute)
+		}
+	})
+
+	f.Set(start.Add(2 * time.Hour))
+
+	want := []string{"07:15", "07:30", "07:45"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("ran at %v, want %v", ran, want)
+	}
+}
+
+func TestRealDrift(t *testing.T) {
+	var r Real
+	if d := r.Drift(start, start.Add(time.Hour)); d != 0 {
+		t.Errorf("Drift() without monotonic readings = %v, want 0", d)
+	}
+	now := time.Now()
+	if d := r.Drift(now, now.Add(time.Minute)); d != 0 {
+		t.Errorf("Drift() with monotonic readings = %v, want 0", d)
+	}
+}
+
+func TestFakeSuspend(t *testing.T) {
+	f := NewFake(start)
+	var ran []string
+	f.AfterFunc(10*time.Minute, func() { ran = append(ran, f.Now().Format("15:04")) })
+
+	before := f.Now()
+	f.Suspend(time.Hour)
+	if got := f.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now() after suspend = %v, want %v", got, start.Add(time.Hour))
+	}
+	if len(ran) > 0 {
+		t.Errorf("timers ran during suspend at %v", ran)
+	}
+
+	// The timer was held up by the suspend, so it is now due at 08:10.
+	f.Set(start.Add(65 * time.Minute))
+	if len(ran) > 0 {
+		t.Errorf("timer ran at %v, want it held up until 08:10", ran)
+	}
+	f.Set(start.Add(70 * time.Minute))
+	if want := []string{"08:10"}; !slices.Equal(ran, want) {
+		t.Errorf("ran at %v, want %v", ran, want)
+	}
+
+	if d := f.Drift(before, f.Now()); d != time.Hour {
+		t.Errorf("Drift() across the suspend = %v, want 1h", d)
+	}
+	if d := f.Drift(start.Add(time.Hour), f.Now()); d != 0 {
+		t.Errorf("Drift() after the suspend = %v, want 0", d)
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChecksConfig sets when a journey's trains are checked, in minutes. Anything
+// left out takes the default, which is trainpal's original schedule; an empty
+// list turns those checks off.
+type ChecksConfig struct {
+	Delay        []int            `yaml:"delay"`         // minutes before each departure, notifying of delays; default 60, 45, 30, 15
+	Status       []int            `yaml:"status"`        // minutes before the first departure, always notifying; default 60, 30
+	Platform     PollConfig       `yaml:"platform"`      // until each train departs; default from 13 minutes before, every 2
+	Departure    PollConfig       `yaml:"departure"`     // until each train has departed; default from departure, every 2
+	Connection   PollConfig       `yaml:"connection"`    // relative to the feeder's departure, until the connection departs; default from 15 minutes before, every 5
+	Arrival      PollConfig       `yaml:"arrival"`       // relative to the last departure, until arrived; default from 70 minutes after, every 5
+	ArrivalDelay PollConfig       `yaml:"arrival_delay"` // relative to the last departure, until arrived; default from 5 minutes after, every 5
+	Tube         TubeChecksConfig `yaml:"tube"`
+}
+
+// PollConfig is a check repeated every Every minutes from Start minutes after
+// a departure, or before it if Start is negative.
+type PollConfig struct {
+	Start *int `yaml:"start"`
+	Every int  `yaml:"every"`
+}
+
+// TubeChecksConfig sets when the Northern Line is checked, for the journey's
+// tube mode.
+type TubeChecksConfig struct {
+	Poll    PollConfig `yaml:"poll"`    // poll: until the departure; default from 60 minutes before, every 5
+	Summary *int       `yaml:"summary"` // poll: minutes before the expected arrival to send a status summary, default 15
+	Check   []int      `yaml:"check"`   // check: minutes before the departure, default 60, 30
+}
+
+// Timings are a journey's check times with the defaults applied, as offsets
+// from the departure (or arrival) they are relative to.
+type Timings struct {
+	Delay        []time.Duration
+	Status       []time.Duration
+	Platform     Poll
+	Departure    Poll
+	Connection   Poll
+	Arrival      Poll
+	ArrivalDelay Poll
+	TubePoll     Poll
+	TubeSummary  time.Duration // before the arrival
+	TubeCheck    []time.Duration
+}
+
+// Poll is a repeating check starting Start after a departure.
+type Poll struct {
+	Start time.Duration
+	Every time.Duration
+}
+
+var defaultChecks = ChecksConfig{
+	Delay:        []int{60, 45, 30, 15},
+	Status:       []int{60, 30},
+	Platform:     PollConfig{Start: minutes(-13), Every: 2},
+	Departure:    PollConfig{Start: minutes(0), Every: 2},
+	Connection:   PollConfig{Start: minutes(-15), Every: 5},
+	Arrival:      PollConfig{Start: minutes(70), Every: 5},
+	ArrivalDelay: PollConfig{Start: minutes(5), Every: 5},
+	Tube: TubeChecksConfig{
+		Poll:    PollConfig{Start: minutes(-60), Every: 5},
+		Summary: minutes(15),
+		Check:   []int{60, 30},
+	},
+}
+
+// minutesPerDay bounds the tube summary, which is relative to an arrival not
+// known until the day's tasks are scheduled.
+const minutesPerDay = 24 * 60
+
+func minutes(n int) *int {
+	return &n
+}
+
+// Timings returns the check times, with the defaults filled in.
+func (c ChecksConfig) Timings() Timings {
+	d := defaultChecks
+	summary := *d.Tube.Summary
+	if c.Tube.Summary != nil {
+		summary = *c.Tube.Summary
+	}
+	return Timings{
+		Delay:        before(c.Delay, d.Delay),
+		Status:       before(c.Status, d.Status),
+		Platform:     c.Platform.poll(d.Platform),
+		Departure:    c.Departure.poll(d.Departure),
+		Connection:   c.Connection.poll(d.Connection),
+		Arrival:      c.Arrival.poll(d.Arrival),
+		ArrivalDelay: c.ArrivalDelay.poll(d.ArrivalDelay),
+		TubePoll:     c.Tube.Poll.poll(d.Tube.Poll),
+		TubeSummary:  time.Duration(summary) * time.Minute,
+		TubeCheck:    before(c.Tube.Check, d.Tube.Check),
+	}
+}
+
+// before converts minutes before a departure into offsets from it, using def
+// if mins was left out.
+func before(mins, def []int) []time.Duration {
+	if mins == nil {
+		mins = def
+	}
+	offsets := make([]time.Duration, len(mins))
+	for i, m := range mins {
+		offsets[i] = -time.Duration(m) * time.Minute
+	}
+	return offsets
+}
+
+func (p PollConfig) poll(def PollConfig) Poll {
+	start, every := *def.Start, def.Every
+	if p.Start != nil {
+		start = *p.Start
+	}
+	if p.Every > 0 {
+		every = p.Every
+	}
+	return Poll{
+		Start: time.Duration(start) * time.Minute,
+		Every: time.Duration(every) * time.Minute,
+	}
+}
+
+// validate checks the timings for a journey's route, whose legs depart in
+// order, and its tube mode. Checks are scheduled on the day they belong to, so
+// each must fall between midnight and the end of the day of its departure.
+// Tube checks must be for the journey's tube mode.
+func (c ChecksConfig) validate(route []Leg, tube string) error {
+	first, err := minuteOfDay(route[0])
+	if err != nil {
+		return err
+	}
+	last, err := minuteOfDay(route[len(route)-1])
+	if err != nil {
+		return err
+	}
+
+	if tube != TubePoll && (c.Tube.Poll != (PollConfig{}) || c.Tube.Summary != nil) {
+		return fmt.Errorf("tube.poll and tube.summary only apply with tube: %s", TubePoll)
+	}
+	if tube != TubeCheck && c.Tube.Check != nil {
+		return fmt.Errorf("tube.check only applies with tube: %s", TubeCheck)
+	}
+
+	lists := []struct {
+		name string
+		mins []int
+	}{
+		{"delay", c.Delay},
+		{"status", c.Status},
+		{"tube.check", c.Tube.Check},
+	}
+	for _, l := range lists {
+		for _, m := range l.mins {
+			if m < 0 || m > first {
+				return fmt.Errorf("%s: %d must be minutes before the %s departure, from 0 to %d", l.name, m, route[0].Departure, first)
+			}
+		}
+	}
+
+	polls := []struct {
+		name     string
+		poll     PollConfig
+		min, max int // allowed start
+	}{
+		{"platform", c.Platform, -first, 0},
+		{"departure", c.Departure, -first, minutesPerDay - 1 - last},
+		{"connection", c.Connection, -first, 0},
+		{"arrival", c.Arrival, 0, minutesPerDay - 1 - last},
+		{"arrival_delay", c.ArrivalDelay, 0, minutesPerDay - 1 - last},
+		{"tube.poll", c.Tube.Poll, -first, 0},
+	}
+	for _, p := range polls {
+		if p.poll.Every < 0 {
+			return fmt.Errorf("%s: every must be positive minutes", p.name)
+		}
+		if s := p.poll.Start; s != nil && (*s < p.min || *s > p.max) {
+			return fmt.Errorf("%s: start must be from %d to %d minutes, to stay on the day of the departure", p.name, p.min, p.max)
+		}
+	}
+
+	if s := c.Tube.Summary; s != nil && (*s < 0 || *s >= minutesPerDay) {
+		return fmt.Errorf("tube.summary: must be minutes before arrival, from 0 to %d", minutesPerDay-1)
+	}
+	return nil
+}
+
+// minuteOfDay returns the minutes after midnight a leg departs.
+func minuteOfDay(leg Leg) (int, error) {
+	// Only the time of day matters here, so any date will do
+	dep, err := leg.DepartureOn(time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	return dep.Hour()*60 + dep.Minute(), nil
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks ChecksConfig
+		want   Timings
+	}{
+		{
+			// trainpal's original schedule
+			name: "defaults",
+			want: Timings{
+				Delay:        []time.Duration{-60 * time.Minute, -45 * time.Minute, -30 * time.Minute, -15 * time.Minute},
+				Status:       []time.Duration{-60 * time.Minute, -30 * time.Minute},
+				Platform:     Poll{Start: -13 * time.Minute, Every: 2 * time.Minute},
+				Departure:    Poll{Start: 0, Every: 2 * time.Minute},
+				Connection:   Poll{Start: -15 * time.Minute, Every: 5 * time.Minute},
+				Arrival:      Poll{Start: 70 * time.Minute, Every: 5 * time.Minute},
+				ArrivalDelay: Poll{Start: 5 * time.Minute, Every: 5 * time.Minute},
+				TubePoll:     Poll{Start: -60 * time.Minute, Every: 5 * time.Minute},
+				TubeSummary:  15 * time.Minute,
+				TubeCheck:    []time.Duration{-60 * time.Minute, -30 * time.Minute},
+			},
+		},
+		{
+			name: "overrides",
+			checks: ChecksConfig{
+				Delay:    []int{20, 10},
+				Status:   []int{},
+				Platform: PollConfig{Every: 1},
+				Arrival:  PollConfig{Start: minutes(0)},
+				Tube: TubeChecksConfig{
+					Summary: minutes(0),
+					Check:   []int{},
+				},
+			},
+			want: Timings{
+				Delay:        []time.Duration{-20 * time.Minute, -10 * time.Minute},
+				Status:       []time.Duration{},
+				Platform:     Poll{Start: -13 * time.Minute, Every: time.Minute},
+				Departure:    Poll{Start: 0, Every: 2 * time.Minute},
+				Connection:   Poll{Start: -15 * time.Minute, Every: 5 * time.Minute},
+				Arrival:      Poll{Start: 0, Every: 5 * time.Minute},
+				ArrivalDelay: Poll{Start: 5 * time.Minute, Every: 5 * time.Minute},
+				TubePoll:     Poll{Start: -60 * time.Minute, Every: 5 * time.Minute},
+				TubeSummary:  0,
+				TubeCheck:    []time.Duration{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.checks.Timings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Timings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// route returns a journey from WIN departing at each time in turn.
+func route(departures ...string) []Leg {
+	stations := []string{"WIN", "WAT", "EUS", "MAN"}
+	legs := make([]Leg, len(departures))
+	for i, dep := range departures {
+		legs[i] = Leg{From: stations[i], To: stations[i+1], Departure: dep}
+	}
+	return legs
+}
+
+func TestChecksValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  ChecksConfig
+		route   []Leg
+		tube    string
+		wantErr string
+	}{
+		{name: "defaults", route: route("0800")},
+		{name: "defaults with tube poll", route: route("0800"), tube: TubePoll},
+		{name: "defaults with tube check", route: route("0800"), tube: TubeCheck},
+		{
+			name:   "empty lists turn checks off",
+			checks: ChecksConfig{Delay: []int{}, Status: []int{}},
+			route:  route("0800"),
+		},
+		{
+			name:   "delay at midnight",
+			checks: ChecksConfig{Delay: []int{60}},
+			route:  route("0100"),
+		},
+		{
+			name:    "delay before midnight",
+			checks:  ChecksConfig{Delay: []int{61}},
+			route:   route("0100"),
+			wantErr: "delay: 61 must be minutes before the 0100 departure, from 0 to 60",
+		},
+		{
+			name:    "delay after departure",
+			checks:  ChecksConfig{Delay: []int{-5}},
+			route:   route("0800"),
+			wantErr: "delay: -5",
+		},
+		{
+			name:    "status before midnight",
+			checks:  ChecksConfig{Status: []int{90}},
+			route:   route("0030"),
+			wantErr: "status: 90",
+		},
+		{
+			name:    "platform after departure",
+			checks:  ChecksConfig{Platform: PollConfig{Start: minutes(5)}},
+			route:   route("0800"),
+			wantErr: "platform: start must be from -480 to 0 minutes",
+		},
+		{
+			name:    "platform before midnight",
+			checks:  ChecksConfig{Platform: PollConfig{Start: minutes(-481)}},
+			route:   route("0800"),
+			wantErr: "platform: start must be from -480 to 0 minutes",
+		},
+		{
+			name:   "arrival up to midnight",
+			checks: ChecksConfig{Arrival: PollConfig{Start: minutes(29)}},
+			route:  route("2330"),
+		},
+		{
+			name:    "arrival after midnight",
+			checks:  ChecksConfig{Arrival: PollConfig{Start: minutes(30)}},
+			route:   route("2330"),
+			wantErr: "arrival: start must be from 0 to 29 minutes",
+		},
+		{
+			name:    "arrival after midnight from the last leg",
+			checks:  ChecksConfig{ArrivalDelay: PollConfig{Start: minutes(30)}},
+			route:   route("2200", "2330"),
+			wantErr: "arrival_delay: start must be from 0 to 29 minutes",
+		},
+		{
+			name:    "arrival before departure",
+			checks:  ChecksConfig{Arrival: PollConfig{Start: minutes(-1)}},
+			route:   route("0800"),
+			wantErr: "arrival: start must be from 0",
+		},
+		{
+			name:    "departure before midnight from the first leg",
+			checks:  ChecksConfig{Departure: PollConfig{Start: minutes(-61)}},
+			route:   route("0100", "0800"),
+			wantErr: "departure: start must be from -60 to 959 minutes",
+		},
+		{
+			name:    "connection after departure",
+			checks:  ChecksConfig{Connection: PollConfig{Start: minutes(1)}},
+			route:   route("0700", "0800"),
+			wantErr: "connection: start must be from -420 to 0 minutes",
+		},
+		{
+			name:    "negative interval",
+			checks:  ChecksConfig{Departure: PollConfig{Every: -1}},
+			route:   route("0800"),
+			wantErr: "departure: every must be positive minutes",
+		},
+		{
+			name:    "tube poll before midnight",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Poll: PollConfig{Start: minutes(-31)}}},
+			route:   route("0030"),
+			tube:    TubePoll,
+			wantErr: "tube.poll: start must be from -30 to 0 minutes",
+		},
+		{
+			name:    "tube summary over a day",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Summary: minutes(minutesPerDay)}},
+			route:   route("0800"),
+			tube:    TubePoll,
+			wantErr: "tube.summary: must be minutes before arrival, from 0 to 1439",
+		},
+		{
+			name:    "tube check before midnight",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Check: []int{45}}},
+			route:   route("0030"),
+			tube:    TubeCheck,
+			wantErr: "tube.check: 45",
+		},
+		{
+			name:   "tube check with tube check",
+			checks: ChecksConfig{Tube: TubeChecksConfig{Check: []int{20}}},
+			route:  route("0800"),
+			tube:   TubeCheck,
+		},
+		{
+			name:    "tube check with tube poll",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Check: []int{20}}},
+			route:   route("0800"),
+			tube:    TubePoll,
+			wantErr: "tube.check only applies with tube: check",
+		},
+		{
+			name:    "tube poll with tube check",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Poll: PollConfig{Every: 10}}},
+			route:   route("0800"),
+			tube:    TubeCheck,
+			wantErr: "tube.poll and tube.summary only apply with tube: poll",
+		},
+		{
+			name:    "tube summary without tube",
+			checks:  ChecksConfig{Tube: TubeChecksConfig{Summary: minutes(10)}},
+			route:   route("0800"),
+			wantErr: "tube.poll and tube.summary only apply with tube: poll",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.checks.validate(tt.route, tt.tube)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,37 +2,117 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// Northern Line check modes for a journey.
+const (
+	TubePoll  = "poll"  // every 5 minutes in the hour before departure, plus a summary before arrival
+	TubeCheck = "check" // 60 and 30 minutes before departure
 )
 
+// DefaultAlternatives is the number of alternative services suggested when a
+// journey does not set alternatives.count.
+const DefaultAlternatives = 3
+
+// DefaultMinConnection is the connection time allowed at an interchange when
+// a leg does not set min_connection.
+const DefaultMinConnection = 5
+
 type TrainConfig struct {
+	Name      string   `yaml:"name"`
 	From      string   `yaml:"from"`
 	To        string   `yaml:"to"`
 	Departure string   `yaml:"departure"`
 	Days      []string `yaml:"days"` // e.g., ["monday", "wednesday", "friday"]
+	Tube      string   `yaml:"tube"` // "poll", "check", or empty for no Northern Line checks
+	Legs      []Leg    `yaml:"legs"` // for journeys that change trains; From, To and Departure are taken from the legs
+
+	Alternatives  AlternativesConfig `yaml:"alternatives"`
+	ArrivalAlerts []int              `yaml:"arrival_alerts"` // arrival delay thresholds in minutes to alert on while en route
+	Checks        ChecksConfig       `yaml:"checks"`         // when to check the trains, default trainpal's original schedule
+}
+
+// AlternativesConfig controls the alternative services suggested when a train
+// is cancelled or badly delayed.
+type AlternativesConfig struct {
+	Count          int `yaml:"count"`           // number of alternatives to suggest, default 3
+	DelayThreshold int `yaml:"delay_threshold"` // minutes of delay that trigger suggestions, 0 for cancellations only
+}
+
+// Limit returns the number of alternatives to suggest.
+func (a AlternativesConfig) Limit() int {
+	if a.Count <= 0 {
+		return DefaultAlternatives
+	}
+	return a.Count
+}
+
+// Leg is a single train within a multi-leg journey.
+type Leg struct {
+	From          string `yaml:"from"`
+	To            string `yaml:"to"`
+	Departure     string `yaml:"departure"`
+	MinConnection int    `yaml:"min_connection"` // minutes needed to change onto this leg
 }
 
-func (t TrainConfig) DepartureTime() (time.Time, error) {
-	parsed, err := time.Parse("1504", t.Departure)
+// DepartureOn returns the leg's departure on the same date as day.
+func (l Leg) DepartureOn(day time.Time) (time.Time, error) {
+	return parseDeparture(l.Departure, day)
+}
+
+// ConnectionTime returns the minimum time needed to change onto this leg.
+func (l Leg) ConnectionTime() time.Duration {
+	if l.MinConnection <= 0 {
+		return DefaultMinConnection * time.Minute
+	}
+	return time.Duration(l.MinConnection) * time.Minute
+}
+
+// DepartureOn returns the journey's departure on the same date as day.
+func (t TrainConfig) DepartureOn(day time.Time) (time.Time, error) {
+	return parseDeparture(t.Departure, day)
+}
+
+// Route returns the legs of the journey. A journey without legs is a single
+// leg from From to To.
+func (t TrainConfig) Route() []Leg {
+	if len(t.Legs) > 0 {
+		return t.Legs
+	}
+	return []Leg{{From: t.From, To: t.To, Departure: t.Departure}}
+}
+
+func parseDeparture(departure string, day time.Time) (time.Time, error) {
+	parsed, err := time.Parse("1504", departure)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid departure time %q: %w", t.Departure, err)
+		return time.Time{}, fmt.Errorf("invalid departure time %q: %w", departure, err)
 	}
-	now := time.Now()
-	return time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.Local), nil
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.Local), nil
 }
 
 // IsActiveDay returns true if the given weekday is in the configured days list.
 // If no days are configured, returns true (runs every day).
 func (t TrainConfig) IsActiveDay(weekday time.Weekday) bool {
-	if len(t.Days) == 0 {
-		return true // No filter, run every day
+	return activeOn(t.Days, weekday)
+}
+
+// activeOn reports whether weekday is in days, or true if days is empty.
+func activeOn(days []string, weekday time.Weekday) bool {
+	if len(days) == 0 {
+		return true // No filter, every day
 	}
 	dayName := strings.ToLower(weekday.String())
-	for _, d := range t.Days {
+	for _, d := range days {
 		if strings.ToLower(d) == dayName {
 			return true
 		}
@@ -40,14 +120,434 @@ func (t TrainConfig) IsActiveDay(weekday time.Weekday) bool {
 	return false
 }
 
-// IsActiveToday returns true if today is an active day for this train config.
-func (t TrainConfig) IsActiveToday() bool {
-	return t.IsActiveDay(time.Now().Weekday())
+func validateDays(days []string) error {
+	for _, d := range days {
+		valid := false
+		for w := time.Sunday; w <= time.Saturday; w++ {
+			if strings.EqualFold(d, w.String()) {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid day %q", d)
+		}
+	}
+	return nil
+}
+
+// applyLegs fills From, To and Departure from the first and last legs.
+func (t *TrainConfig) applyLegs() {
+	if len(t.Legs) == 0 {
+		return
+	}
+	t.From = t.Legs[0].From
+	t.To = t.Legs[len(t.Legs)-1].To
+	t.Departure = t.Legs[0].Departure
+}
+
+func (t TrainConfig) validate() error {
+	if len(t.Legs) == 1 {
+		return fmt.Errorf("legs: at least two legs are required, use from/to/departure for a direct train")
+	}
+	for i, leg := range t.Legs {
+		if leg.From == "" || leg.To == "" || leg.Departure == "" {
+			return fmt.Errorf("legs[%d]: from, to, and departure are required", i)
+		}
+		// Only the time of day matters here, so any date will do
+		dep, err := leg.DepartureOn(time.Time{})
+		if err != nil {
+			return fmt.Errorf("legs[%d]: %w", i, err)
+		}
+		if leg.MinConnection < 0 {
+			return fmt.Errorf("legs[%d]: min_connection must not be negative", i)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := t.Legs[i-1]
+		if leg.From != prev.To {
+			return fmt.Errorf("legs[%d]: from %s does not match previous leg's to %s", i, leg.From, prev.To)
+		}
+		if prevDep, _ := prev.DepartureOn(time.Time{}); !dep.After(prevDep) {
+			return fmt.Errorf("legs[%d]: departure %s must be after previous leg's departure %s", i, leg.Departure, prev.Departure)
+		}
+	}
+
+	if t.From == "" || t.To == "" || t.Departure == "" {
+		return fmt.Errorf("from, to, and departure are required")
+	}
+	if _, err := t.DepartureOn(time.Time{}); err != nil {
+		return err
+	}
+	if t.Alternatives.Count < 0 {
+		return fmt.Errorf("alternatives: count must not be negative")
+	}
+	if t.Alternatives.DelayThreshold < 0 {
+		return fmt.Errorf("alternatives: delay_threshold must not be negative")
+	}
+
+	for _, threshold := range t.ArrivalAlerts {
+		if threshold <= 0 {
+			return fmt.Errorf("arrival_alerts: thresholds must be positive minutes, got %d", threshold)
+		}
+	}
+
+	switch t.Tube {
+	case "", TubePoll, TubeCheck:
+	default:
+		return fmt.Errorf("invalid tube mode %q: must be %q or %q", t.Tube, TubePoll, TubeCheck)
+	}
+
+	if err := t.Checks.validate(t.Route(), t.Tube); err != nil {
+		return fmt.Errorf("checks: %w", err)
+	}
+	return nil
+}
+
+// Notifier backend types.
+const (
+	NotifierPushover = "pushover"
+	NotifierNtfy     = "ntfy"
+	NotifierSlack    = "slack"
+	NotifierDiscord  = "discord"
+	NotifierEmail    = "email"
+	NotifierTelegram = "telegram"
+	NotifierWebhook  = "webhook"
+)
+
+// NotifierConfig configures one notification backend. Credentials left empty
+// are read from the backend's environment variables.
+type NotifierConfig struct {
+	Type   string `yaml:"type"`
+	Name   string `yaml:"name"`    // used to mute this notifier and in logs, default the type
+	Token  string `yaml:"token"`   // pushover: application token, default $PUSHOVER_TOKEN; ntfy: access token, default $NTFY_TOKEN; telegram: bot token, default $TELEGRAM_BOT_TOKEN
+	User   string `yaml:"user"`    // pushover: user key, default $PUSHOVER_USER
+	URL    string `yaml:"url"`     // ntfy: server URL, default https://ntfy.sh; slack/discord: webhook URL, default $SLACK_WEBHOOK_URL/$DISCORD_WEBHOOK_URL; telegram: Bot API URL, default https://api.telegram.org; webhook: endpoint
+	Topic  string `yaml:"topic"`   // ntfy: topic to publish to
+	ChatID string `yaml:"chat_id"` // telegram: chat to send alerts to and accept commands from, default $TELEGRAM_CHAT_ID
+	Secret string `yaml:"secret"`  // webhook: HMAC-SHA256 signing key, default $WEBHOOK_SECRET
+
+	// email: SMTP server, default $SMTP_HOST and $SMTP_PORT or 587. The
+	// username and password are read from $SMTP_USERNAME and $SMTP_PASSWORD.
+	Host      string   `yaml:"host"`
+	Port      int      `yaml:"port"`
+	From      string   `yaml:"from"`
+	To        []string `yaml:"to"`
+	Digest    string   `yaml:"digest"`    // email: send one digest of the day's alerts at this time (HHMM) instead of each alert
+	Plaintext bool     `yaml:"plaintext"` // email: allow servers that don't offer STARTTLS, such as a local relay
+
+	Emergency  *EmergencyConfig `yaml:"emergency"` // pushover: send matching alerts at emergency priority
+	QuietHours []QuietHours     `yaml:"quiet_hours"`
+}
+
+// Limits Pushover places on emergency notifications.
+const (
+	DefaultEmergencyRetry  = 60    // seconds
+	DefaultEmergencyExpire = 3600  // seconds
+	minEmergencyRetry      = 30    // seconds
+	maxEmergencyExpire     = 10800 // seconds
+)
+
+// EmergencyConfig sends matching alerts through Pushover at emergency
+// priority, which repeats them until they are acknowledged.
+type EmergencyConfig struct {
+	Kinds    []string       `yaml:"kinds"`    // event kinds, default train_cancellation
+	Journeys []string       `yaml:"journeys"` // default every journey
+	Retry    int            `yaml:"retry"`    // seconds between repeats, default 60, at least 30
+	Expire   int            `yaml:"expire"`   // seconds to keep repeating, default 3600, at most 10800
+	Escalate EscalateConfig `yaml:"escalate"`
+}
+
+// EscalateConfig passes an emergency alert that has not been acknowledged on
+// to a second Pushover user or group.
+type EscalateConfig struct {
+	User   string `yaml:"user"`   // user or group key, empty to never escalate
+	Before int    `yaml:"before"` // minutes before departure to escalate; alerts without a departure escalate when they expire
+}
+
+// RetryInterval returns how often Pushover repeats the alert.
+func (e EmergencyConfig) RetryInterval() time.Duration {
+	if e.Retry <= 0 {
+		return DefaultEmergencyRetry * time.Second
+	}
+	return time.Duration(e.Retry) * time.Second
+}
+
+// ExpireAfter returns how long Pushover keeps repeating the alert.
+func (e EmergencyConfig) ExpireAfter() time.Duration {
+	if e.Expire <= 0 {
+		return DefaultEmergencyExpire * time.Second
+	}
+	return time.Duration(e.Expire) * time.Second
+}
+
+func (e EmergencyConfig) validate(journeys map[string]bool) error {
+	for _, k := range e.Kinds {
+		if _, ok := events.Example(k); !ok {
+			return fmt.Errorf("unknown event kind %q", k)
+		}
+	}
+	for _, j := range e.Journeys {
+		if !journeys[j] {
+			return fmt.Errorf("unknown journey %q", j)
+		}
+	}
+	if e.Retry != 0 && e.Retry < minEmergencyRetry {
+		return fmt.Errorf("retry must be at least %d seconds", minEmergencyRetry)
+	}
+	if e.Expire < 0 || e.Expire > maxEmergencyExpire {
+		return fmt.Errorf("expire must be at most %d seconds", maxEmergencyExpire)
+	}
+	if e.Escalate.Before < 0 {
+		return fmt.Errorf("escalate.before must not be negative")
+	}
+	return nil
+}
+
+// DisplayName returns the notifier's name, or its type if it has none.
+func (n NotifierConfig) DisplayName() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	return n.Type
+}
+
+// QuietHours is a daily window in which a notifier's normal priority alerts
+// are held back. High priority alerts, such as cancellations, still get through.
+type QuietHours struct {
+	From  string   `yaml:"from"`  // HHMM
+	To    string   `yaml:"to"`    // HHMM, on the next day if before From; the same as From for the whole day
+	Days  []string `yaml:"days"`  // days the window starts on, default every day
+	Defer bool     `yaml:"defer"` // deliver held back alerts when the window ends instead of dropping them
+}
+
+// Window returns the quiet window containing t, if there is one.
+func (q QuietHours) Window(t time.Time) (start, end time.Time, ok bool) {
+	from, err := time.Parse("1504", q.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	to, err := time.Parse("1504", q.To)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	// A window that started yesterday may still be open.
+	for _, offset := range []int{0, -1} {
+		day := t.AddDate(0, 0, offset)
+		if !activeOn(q.Days, day.Weekday()) {
+			continue
+		}
+		start = time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, t.Location())
+		end = time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, t.Location())
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (q QuietHours) validate() error {
+	if _, err := time.Parse("1504", q.From); err != nil {
+		return fmt.Errorf("invalid from time %q: %w", q.From, err)
+	}
+	if _, err := time.Parse("1504", q.To); err != nil {
+		return fmt.Errorf("invalid to time %q: %w", q.To, err)
+	}
+	return validateDays(q.Days)
+}
+
+// DigestTime returns the time of day the digest is sent, as an offset from
+// midnight.
+func (n NotifierConfig) DigestTime() (time.Duration, error) {
+	t, err := time.Parse("1504", n.Digest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid digest time %q: %w", n.Digest, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (n NotifierConfig) validate(journeys map[string]bool) error {
+	if err := n.validateBackend(); err != nil {
+		return err
+	}
+	if n.Emergency != nil {
+		if n.Type != NotifierPushover {
+			return fmt.Errorf("%s: emergency is only supported by pushover", n.Type)
+		}
+		if err := n.Emergency.validate(journeys); err != nil {
+			return fmt.Errorf("emergency: %w", err)
+		}
+	}
+	for i, q := range n.QuietHours {
+		if err := q.validate(); err != nil {
+			return fmt.Errorf("quiet_hours[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (n NotifierConfig) validateBackend() error {
+	switch n.Type {
+	case NotifierPushover, NotifierSlack, NotifierDiscord, NotifierTelegram:
+		return nil
+	case NotifierNtfy:
+		if n.Topic == "" {
+			return fmt.Errorf("ntfy: topic is required")
+		}
+		return nil
+	case NotifierWebhook:
+		if n.URL == "" {
+			return fmt.Errorf("webhook: url is required")
+		}
+		if n.Secret == "" && os.Getenv("WEBHOOK_SECRET") == "" {
+			return fmt.Errorf("webhook: secret is required (or WEBHOOK_SECRET)")
+		}
+		return nil
+	case NotifierEmail:
+		if n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("email: from and to are required")
+		}
+		if n.Digest != "" {
+			if _, err := n.DigestTime(); err != nil {
+				return fmt.Errorf("email: %w", err)
+			}
+		}
+		return nil
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown type %q", n.Type)
+	}
+}
+
+// TemplateConfig overrides the wording of one kind of notification with Go
+// text/templates executed against the event, e.g. "{{.ServiceUID}} +{{.DelayMinutes}}m".
+// An empty title or body keeps the default.
+type TemplateConfig struct {
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+}
+
+// Parse parses the title and body templates for events of the given kind.
+// Either is nil if it is not overridden.
+func (t TemplateConfig) Parse(kind string) (title, body *template.Template, err error) {
+	if t.Title != "" {
+		if title, err = template.New(kind + ".title").Parse(t.Title); err != nil {
+			return nil, nil, fmt.Errorf("title: %w", err)
+		}
+	}
+	if t.Body != "" {
+		if body, err = template.New(kind + ".body").Parse(t.Body); err != nil {
+			return nil, nil, fmt.Errorf("body: %w", err)
+		}
+	}
+	return title, body, nil
+}
+
+// validate parses the templates and runs them against example events, so
+// references to fields the event does not have are caught at load, as are
+// optional fields used without {{with}} or {{if}}.
+func (t TemplateConfig) validate(kind string) error {
+	examples, ok := events.Examples(kind)
+	if !ok {
+		return fmt.Errorf("unknown event kind")
+	}
+	title, body, err := t.Parse(kind)
+	if err != nil {
+		return err
+	}
+	for _, example := range examples {
+		if title != nil {
+			if err := title.Execute(io.Discard, example); err != nil {
+				return fmt.Errorf("title: %w", err)
+			}
+		}
+		if body != nil {
+			if err := body.Execute(io.Discard, example); err != nil {
+				return fmt.Errorf("body: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Severities of notifications, from least to most severe.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityDanger  = "danger"
+)
+
+// RecipientConfig is a person or group with their own notifiers, who receives
+// alerts for the journeys they subscribe to.
+type RecipientConfig struct {
+	Name        string           `yaml:"name"`
+	Journeys    []string         `yaml:"journeys"`     // default every journey
+	MinSeverity string           `yaml:"min_severity"` // info, warning or danger, default info
+	Notifiers   []NotifierConfig `yaml:"notifiers"`
+}
+
+func (r RecipientConfig) validate(journeys map[string]bool) error {
+	for _, j := range r.Journeys {
+		if !journeys[j] {
+			return fmt.Errorf("unknown journey %q", j)
+		}
+	}
+	switch r.MinSeverity {
+	case "", SeverityInfo, SeverityWarning, SeverityDanger:
+	default:
+		return fmt.Errorf("invalid min_severity %q, want info, warning or danger", r.MinSeverity)
+	}
+	if len(r.Notifiers) == 0 {
+		return fmt.Errorf("at least one notifier is required")
+	}
+	return validateNotifiers(r.Notifiers, journeys)
+}
+
+// Subscribes reports whether the recipient receives alerts for the journey.
+func (r RecipientConfig) Subscribes(journey string) bool {
+	if len(r.Journeys) == 0 {
+		return true
+	}
+	for _, j := range r.Journeys {
+		if j == journey {
+			return true
+		}
+	}
+	return false
 }
 
 type Config struct {
-	MorningTrain TrainConfig `yaml:"morning_train"`
-	EveningTrain TrainConfig `yaml:"evening_train"`
+	Journeys   []TrainConfig             `yaml:"journeys"`
+	Notifiers  []NotifierConfig          `yaml:"notifiers"`  // receive every alert; defaults to Pushover from the environment if there are no recipients
+	Recipients []RecipientConfig         `yaml:"recipients"` // receive alerts for the journeys they subscribe to
+	Templates  map[string]TemplateConfig `yaml:"templates"`  // keyed by event kind, e.g. train_delay
+	CatchUp    string                    `yaml:"catch_up"`   // what to do with checks missed while suspended, default latest
+
+	// MorningTrain and EveningTrain are the original fixed journeys. They are
+	// still accepted and are converted into journeys named "morning" and
+	// "evening" when the config is loaded.
+	MorningTrain *TrainConfig `yaml:"morning_train"`
+	EveningTrain *TrainConfig `yaml:"evening_train"`
+}
+
+// Catch-up policies, for checks that fell due while trainpal could not run
+// them, such as while the machine was suspended.
+const (
+	CatchUpLatest = "latest" // run the latest missed check of each kind for each train
+	CatchUpAll    = "all"    // run every missed check
+	CatchUpSkip   = "skip"   // run none of them
+)
+
+// CatchUpPolicy returns the catch-up policy, defaulting to CatchUpLatest.
+func (c *Config) CatchUpPolicy() string {
+	if c.CatchUp == "" {
+		return CatchUpLatest
+	}
+	return c.CatchUp
 }
 
 func Load(path string) (*Config, error) {
@@ -61,6 +561,14 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
 
+	cfg.migrateLegacyTrains()
+	for i := range cfg.Journeys {
+		cfg.Journeys[i].applyLegs()
+	}
+	if len(cfg.Notifiers) == 0 && len(cfg.Recipients) == 0 {
+		cfg.Notifiers = []NotifierConfig{{Type: NotifierPushover}}
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -68,19 +576,134 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// migrateLegacyTrains converts morning_train and evening_train into named
+// journeys, keeping the Northern Line checks they always had.
+func (c *Config) migrateLegacyTrains() {
+	var legacy []TrainConfig
+	if c.MorningTrain != nil {
+		t := *c.MorningTrain
+		if t.Name == "" {
+			t.Name = "morning"
+		}
+		if t.Tube == "" {
+			t.Tube = TubePoll
+		}
+		legacy = append(legacy, t)
+	}
+	if c.EveningTrain != nil {
+		t := *c.EveningTrain
+		if t.Name == "" {
+			t.Name = "evening"
+		}
+		if t.Tube == "" {
+			t.Tube = TubeCheck
+		}
+		legacy = append(legacy, t)
+	}
+	c.Journeys = append(legacy, c.Journeys...)
+	c.MorningTrain = nil
+	c.EveningTrain = nil
+}
+
+// NextJourney returns the journey active today with the next departure after
+// now, or nil if there are no more today.
+func (c *Config) NextJourney(now time.Time) *TrainConfig {
+	var next *TrainConfig
+	var nextDep time.Time
+	for i := range c.Journeys {
+		j := &c.Journeys[i]
+		if !j.IsActiveDay(now.Weekday()) {
+			continue
+		}
+		dep, err := j.DepartureOn(now)
+		if err != nil || dep.Before(now) {
+			continue
+		}
+		if next == nil || dep.Before(nextDep) {
+			next, nextDep = j, dep
+		}
+	}
+	return next
+}
+
+// Journey returns the journey with the given name, or nil if there is none.
+func (c *Config) Journey(name string) *TrainConfig {
+	for i := range c.Journeys {
+		if c.Journeys[i].Name == name {
+			return &c.Journeys[i]
+		}
+	}
+	return nil
+}
+
+func validateNotifiers(notifiers []NotifierConfig, journeys map[string]bool) error {
+	names := make(map[string]bool)
+	for i, n := range notifiers {
+		if err := n.validate(journeys); err != nil {
+			return fmt.Errorf("notifiers[%d]: %w", i, err)
+		}
+		if names[n.DisplayName()] {
+			return fmt.Errorf("notifiers[%d]: duplicate name %q, set a name to tell them apart", i, n.DisplayName())
+		}
+		names[n.DisplayName()] = true
+	}
+	return nil
+}
+
 func (c *Config) Validate() error {
-	if c.MorningTrain.From == "" || c.MorningTrain.To == "" || c.MorningTrain.Departure == "" {
-		return fmt.Errorf("morning_train: from, to, and departure are required")
+	if len(c.Journeys) == 0 {
+		return fmt.Errorf("at least one journey is required")
+	}
+
+	seen := make(map[string]bool)
+	for i, j := range c.Journeys {
+		if j.Name == "" {
+			return fmt.Errorf("journeys[%d]: name is required", i)
+		}
+		if seen[j.Name] {
+			return fmt.Errorf("journeys[%d]: duplicate name %q", i, j.Name)
+		}
+		seen[j.Name] = true
+
+		if err := j.validate(); err != nil {
+			return fmt.Errorf("journey %q: %w", j.Name, err)
+		}
 	}
-	if c.EveningTrain.From == "" || c.EveningTrain.To == "" || c.EveningTrain.Departure == "" {
-		return fmt.Errorf("evening_train: from, to, and departure are required")
+
+	if err := validateNotifiers(c.Notifiers, seen); err != nil {
+		return err
 	}
 
-	if _, err := c.MorningTrain.DepartureTime(); err != nil {
-		return fmt.Errorf("morning_train: %w", err)
+	recipients := make(map[string]bool)
+	for i, r := range c.Recipients {
+		if r.Name == "" {
+			return fmt.Errorf("recipients[%d]: name is required", i)
+		}
+		if recipients[r.Name] {
+			return fmt.Errorf("recipients[%d]: duplicate name %q", i, r.Name)
+		}
+		recipients[r.Name] = true
+
+		if err := r.validate(seen); err != nil {
+			return fmt.Errorf("recipient %q: %w", r.Name, err)
+		}
+	}
+
+	switch c.CatchUp {
+	case "", CatchUpLatest, CatchUpAll, CatchUpSkip:
+	default:
+		return fmt.Errorf("catch_up: must be %s, %s or %s", CatchUpLatest, CatchUpAll, CatchUpSkip)
 	}
-	if _, err := c.EveningTrain.DepartureTime(); err != nil {
-		return fmt.Errorf("evening_train: %w", err)
+
+	kinds := make([]string, 0, len(c.Templates))
+	for kind := range c.Templates {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if err := c.Templates[kind].validate(kind); err != nil {
+			return fmt.Errorf("templates.%s: %w", kind, err)
+		}
 	}
 
 	return nil
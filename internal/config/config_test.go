@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadYAML loads a config file holding data.
+func loadYAML(t *testing.T, data string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadLegacyTrains(t *testing.T) {
+	cfg, err := loadYAML(t, `
+morning_train:
+  from: "WIN"
+  to: "WAT"
+  departure: "0725"
+  days:
+    - wednesday
+
+evening_train:
+  from: "WAT"
+  to: "WIN"
+  departure: "1635"
+  days:
+    - wednesday
+`)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// The original trains checked the Northern Line by polling before the
+	// morning train and at fixed times before the evening one.
+	want := []TrainConfig{
+		{Name: "morning", From: "WIN", To: "WAT", Departure: "0725", Days: []string{"wednesday"}, Tube: TubePoll},
+		{Name: "evening", From: "WAT", To: "WIN", Departure: "1635", Days: []string{"wednesday"}, Tube: TubeCheck},
+	}
+	if !reflect.DeepEqual(cfg.Journeys, want) {
+		t.Errorf("Journeys = %+v, want %+v", cfg.Journeys, want)
+	}
+	if cfg.MorningTrain != nil || cfg.EveningTrain != nil {
+		t.Errorf("legacy trains left after migration: %+v, %+v", cfg.MorningTrain, cfg.EveningTrain)
+	}
+	if want := []NotifierConfig{{Type: NotifierPushover}}; !reflect.DeepEqual(cfg.Notifiers, want) {
+		t.Errorf("Notifiers = %+v, want %+v", cfg.Notifiers, want)
+	}
+}
+
+func TestLoadLegacyTrainsWithJourneys(t *testing.T) {
+	cfg, err := loadYAML(t, `
+morning_train:
+  name: "commute"
+  from: "WIN"
+  to: "WAT"
+  departure: "0725"
+  tube: check
+
+journeys:
+  - name: "weekend"
+    from: "WIN"
+    to: "SOU"
+    departure: "1000"
+    days: [saturday]
+`)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var names []string
+	for _, j := range cfg.Journeys {
+		names = append(names, j.Name)
+	}
+	if want := []string{"commute", "weekend"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("journeys = %v, want %v", names, want)
+	}
+	if j := cfg.Journey("commute"); j == nil || j.Tube != TubeCheck {
+		t.Errorf("commute = %+v, want its own name and tube mode kept", j)
+	}
+}
+
+func TestLoadLegacyTrainNameClash(t *testing.T) {
+	_, err := loadYAML(t, `
+morning_train:
+  from: "WIN"
+  to: "WAT"
+  departure: "0725"
+
+journeys:
+  - name: "morning"
+    from: "WIN"
+    to: "SOU"
+    departure: "0800"
+`)
+	if err == nil {
+		t.Fatal("Load() with a journey named like a legacy train succeeded")
+	}
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"errors"
+	"sync"
+)
+
+// Handler handles a published event.
+type Handler func(e Event) error
+
+// Publisher publishes events.
+type Publisher interface {
+	Publish(e Event) error
+}
+
+// Bus delivers each published event to every subscriber, in the order they
+// subscribed. Handlers run synchronously, so Publish returns once every
+// subscriber has handled the event.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a handler for every event published from now on.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish hands the event to every subscriber, returning the combined errors
+// of any that fail. A failing subscriber does not stop the others.
+func (b *Bus) Publish(e Event) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,35 @@
+package events
+
+import "sync"
+
+// Counter counts published events by kind.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[string]int)}
+}
+
+// Handle counts the event, unless it is a Record. It never fails.
+func (c *Counter) Handle(e Event) error {
+	if _, ok := e.(Record); ok {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[e.Kind()]++
+	return nil
+}
+
+// Counts returns the number of events of each kind seen so far.
+func (c *Counter) Counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int, len(c.counts))
+	for kind, n := range c.counts {
+		counts[kind] = n
+	}
+	return counts
+}
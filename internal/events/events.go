@@ -0,0 +1,180 @@
+package events
+
+// Event kinds.
+const (
+	KindTrainDelayed       = "train_delay"
+	KindTrainOnTime        = "train_on_time"
+	KindArrivalDelayed     = "arrival_delay"
+	KindTrainArrived       = "train_arrival"
+	KindDelayRepayEligible = "delay_repay"
+	KindTrainDeparted      = "train_departure"
+	KindPlatformConfirmed  = "platform_confirmed"
+	KindPlatformChanged    = "platform_changed"
+	KindTrainCancelled     = "train_cancellation"
+	KindTubeStatusChanged  = "tube_disruption"
+	KindTubeStatusSummary  = "tube_status"
+	KindConnectionAtRisk   = "connection_at_risk"
+	KindConnectionMissed   = "connection_missed"
+)
+
+// Event is something a monitor detected.
+type Event interface {
+	Kind() string
+}
+
+// Service identifies the service an event is about.
+type Service struct {
+	Journey    string `json:"journey,omitempty"`
+	ServiceUID string `json:"service_uid"`
+	RunDate    string `json:"run_date,omitempty"`
+	Departure  string `json:"departure,omitempty"` // booked departure from From, HHMM
+	Operator   string `json:"operator,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+}
+
+// ServiceEvent is an event about a train service.
+type ServiceEvent interface {
+	Event
+	EventService() Service
+}
+
+// EventService returns the service, so every event embedding a Service is a
+// ServiceEvent.
+func (s Service) EventService() Service {
+	return s
+}
+
+// TrainDelayed is a train running late before it departs.
+type TrainDelayed struct {
+	Service
+	DelayMinutes int              `json:"delay_minutes"`
+	ExpectedTime string           `json:"expected_time"`
+	Platform     string           `json:"platform"`
+	Arrival      *ArrivalEstimate `json:"arrival,omitempty"`
+	Alternatives []Alternative    `json:"alternatives,omitempty"`
+}
+
+// TrainOnTime is a status check finding the train running on time.
+type TrainOnTime struct {
+	Service
+	DepartureTime string           `json:"departure_time"`
+	Platform      string           `json:"platform"`
+	Arrival       *ArrivalEstimate `json:"arrival,omitempty"`
+}
+
+// ArrivalDelayed is a train en route crossing an arrival delay threshold.
+type ArrivalDelayed struct {
+	Service
+	Arrival ArrivalEstimate `json:"arrival"`
+}
+
+// TrainArrived is a train arriving at its destination, To.
+type TrainArrived struct {
+	Service
+	ArrivalTime string `json:"arrival_time"`
+}
+
+// DelayRepayEligible is a train arriving late enough to claim Delay Repay.
+type DelayRepayEligible struct {
+	Service
+	BookedDeparture string `json:"booked_departure"`
+	BookedArrival   string `json:"booked_arrival"`
+	ActualArrival   string `json:"actual_arrival"`
+	DelayMinutes    int    `json:"delay_minutes"`
+	Band            int    `json:"band"`
+}
+
+// TrainDeparted is a train leaving its origin.
+type TrainDeparted struct {
+	Service
+	DepartureTime string `json:"departure_time"`
+	Platform      string `json:"platform"`
+}
+
+// PlatformConfirmed is the first platform announced for a train.
+type PlatformConfirmed struct {
+	Service
+	DepartureTime string `json:"departure_time"`
+	Platform      string `json:"platform"`
+}
+
+// PlatformChanged is a train moving to a different platform.
+type PlatformChanged struct {
+	Service
+	DepartureTime    string `json:"departure_time"`
+	PreviousPlatform string `json:"previous_platform"`
+	Platform         string `json:"platform"`
+}
+
+// TrainCancelled is a train being cancelled.
+type TrainCancelled struct {
+	Service
+	Reason       string        `json:"reason"`
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+}
+
+// TubeStatusChanged is the Northern Line's status changing or being disrupted.
+type TubeStatusChanged struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// TubeStatusSummary is the Northern Line's status before arrival.
+type TubeStatusSummary struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// ConnectionAtRisk is a late train leaving little time to change. Service is
+// the connecting train, departing From.
+type ConnectionAtRisk struct {
+	Service
+	FeederUID     string       `json:"feeder_uid"`
+	ArrivalTime   string       `json:"arrival_time"`
+	DepartureTime string       `json:"departure_time"`
+	SlackMinutes  int          `json:"slack_minutes"`
+	Next          *Alternative `json:"next,omitempty"`
+}
+
+// ConnectionMissed is a late train arriving after its connection departs.
+// Service is the connecting train, departing From.
+type ConnectionMissed struct {
+	Service
+	FeederUID       string       `json:"feeder_uid"`
+	FeederCancelled bool         `json:"feeder_cancelled,omitempty"` // ArrivalTime is empty if set
+	ArrivalTime     string       `json:"arrival_time"`
+	DepartureTime   string       `json:"departure_time"`
+	Next            *Alternative `json:"next,omitempty"`
+}
+
+func (TrainDelayed) Kind() string       { return KindTrainDelayed }
+func (TrainOnTime) Kind() string        { return KindTrainOnTime }
+func (ArrivalDelayed) Kind() string     { return KindArrivalDelayed }
+func (TrainArrived) Kind() string       { return KindTrainArrived }
+func (DelayRepayEligible) Kind() string { return KindDelayRepayEligible }
+func (TrainDeparted) Kind() string      { return KindTrainDeparted }
+func (PlatformConfirmed) Kind() string  { return KindPlatformConfirmed }
+func (PlatformChanged) Kind() string    { return KindPlatformChanged }
+func (TrainCancelled) Kind() string     { return KindTrainCancelled }
+func (TubeStatusChanged) Kind() string  { return KindTubeStatusChanged }
+func (TubeStatusSummary) Kind() string  { return KindTubeStatusSummary }
+func (ConnectionAtRisk) Kind() string   { return KindConnectionAtRisk }
+func (ConnectionMissed) Kind() string   { return KindConnectionMissed }
+
+// ArrivalEstimate is the expected arrival of a train at its destination.
+type ArrivalEstimate struct {
+	Station      string `json:"station"`
+	Booked       string `json:"booked"`
+	Expected     string `json:"expected"`
+	DelayMinutes int    `json:"delay_minutes"`
+}
+
+// Alternative describes another service that can be taken instead of the
+// monitored one.
+type Alternative struct {
+	ServiceUID string `json:"service_uid"`
+	Departure  string `json:"departure"`
+	Arrival    string `json:"arrival,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// exampleService is the service used in example events.
+var exampleService = Service{
+	Journey:    "morning",
+	ServiceUID: "W12345",
+	RunDate:    "2025-01-06",
+	Departure:  "0715",
+	Operator:   "South Western Railway",
+	From:       "WAT",
+	To:         "SUR",
+}
+
+var exampleArrival = ArrivalEstimate{Station: "SUR", Booked: "0738", Expected: "0750", DelayMinutes: 12}
+
+var exampleAlternative = Alternative{ServiceUID: "W12346", Departure: "0735", Arrival: "0753", Platform: "5"}
+
+// examples has one event of every kind, with every field set.
+var examples = map[string]Event{
+	KindTrainDelayed: TrainDelayed{
+		Service:      exampleService,
+		DelayMinutes: 12,
+		ExpectedTime: "0732",
+		Platform:     "4",
+		Arrival:      &exampleArrival,
+		Alternatives: []Alternative{exampleAlternative},
+	},
+	KindTrainOnTime: TrainOnTime{
+		Service:       exampleService,
+		DepartureTime: "0720",
+		Platform:      "4",
+		Arrival:       &exampleArrival,
+	},
+	KindArrivalDelayed: ArrivalDelayed{
+		Service: exampleService,
+		Arrival: exampleArrival,
+	},
+	KindTrainArrived: TrainArrived{
+		Service:     exampleService,
+		ArrivalTime: "0750",
+	},
+	KindDelayRepayEligible: DelayRepayEligible{
+		Service:         exampleService,
+		BookedDeparture: "0720",
+		BookedArrival:   "0738",
+		ActualArrival:   "0755",
+		DelayMinutes:    17,
+		Band:            15,
+	},
+	KindTrainDeparted: TrainDeparted{
+		Service:       exampleService,
+		DepartureTime: "0721",
+		Platform:      "4",
+	},
+	KindPlatformConfirmed: PlatformConfirmed{
+		Service:       exampleService,
+		DepartureTime: "0720",
+		Platform:      "4",
+	},
+	KindPlatformChanged: PlatformChanged{
+		Service:          exampleService,
+		DepartureTime:    "0720",
+		PreviousPlatform: "4",
+		Platform:         "6",
+	},
+	KindTrainCancelled: TrainCancelled{
+		Service:      exampleService,
+		Reason:       "a fault with the signalling system",
+		Alternatives: []Alternative{exampleAlternative},
+	},
+	KindTubeStatusChanged: TubeStatusChanged{
+		Status: "Minor Delays",
+		Reason: "Northern Line: Minor delays due to an earlier faulty train.",
+	},
+	KindTubeStatusSummary: TubeStatusSummary{
+		Status: "Good Service",
+	},
+	KindConnectionAtRisk: ConnectionAtRisk{
+		Service:       exampleService,
+		FeederUID:     "W12300",
+		ArrivalTime:   "0748",
+		DepartureTime: "0751",
+		SlackMinutes:  3,
+		Next:          &exampleAlternative,
+	},
+	KindConnectionMissed: ConnectionMissed{
+		Service:       exampleService,
+		FeederUID:     "W12300",
+		ArrivalTime:   "0755",
+		DepartureTime: "0751",
+		Next:          &exampleAlternative,
+	},
+}
+
+// Example returns an example event of the given kind, or false if the kind is
+// unknown.
+func Example(kind string) (Event, bool) {
+	e, ok := examples[kind]
+	return e, ok
+}
+
+// Examples returns example events of the given kind, or false if the kind is
+// unknown: one with every field set, and one with only the service set, so
+// that optional fields such as Arrival and Next are nil or empty as they are
+// when not known.
+func Examples(kind string) ([]Event, bool) {
+	full, ok := examples[kind]
+	if !ok {
+		return nil, false
+	}
+	sparse := reflect.New(reflect.TypeOf(full)).Elem()
+	if service := sparse.FieldByName("Service"); service.IsValid() {
+		service.Set(reflect.ValueOf(exampleService))
+	}
+	return []Event{full, sparse.Interface().(Event)}, true
+}
+
+// Decode decodes the JSON encoding of an event of the given kind.
+func Decode(kind string, data []byte) (Event, error) {
+	example, ok := examples[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown event kind %q", kind)
+	}
+	e := reflect.New(reflect.TypeOf(example))
+	if err := json.Unmarshal(data, e.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", kind, err)
+	}
+	return e.Elem().Interface().(Event), nil
+}
@@ -0,0 +1,96 @@
+package events
+
+import "time"
+
+// Kinds of record events.
+const (
+	KindServiceObserved    = "service_observed"
+	KindTubeStatusObserved = "tube_status_observed"
+	KindNotified           = "notified"
+	KindDelayRepayClaimed  = "delay_repay_claimed"
+)
+
+// Record is an event that only updates the journey history. Records are not
+// notifications, so the notifier and counter ignore them.
+type Record interface {
+	Event
+	record()
+}
+
+// Observation is what a monitor saw of a service during one check. Empty
+// fields leave the recorded values unchanged.
+type Observation struct {
+	Journey         string `json:"journey,omitempty"`
+	ServiceUID      string `json:"service_uid"`
+	From            string `json:"from,omitempty"`
+	To              string `json:"to,omitempty"`
+	BookedDeparture string `json:"booked_departure,omitempty"`
+	ActualDeparture string `json:"actual_departure,omitempty"`
+	BookedArrival   string `json:"booked_arrival,omitempty"`
+	ActualArrival   string `json:"actual_arrival,omitempty"`
+	Departed        bool   `json:"departed,omitempty"`
+	Arrived         bool   `json:"arrived,omitempty"`
+	DelayMinutes    int    `json:"delay_minutes,omitempty"`
+	ArrivalDelay    int    `json:"arrival_delay,omitempty"`
+	JourneyDelay    int    `json:"journey_delay,omitempty"` // end to end, against the journey's booked arrival
+	Cancelled       bool   `json:"cancelled,omitempty"`
+	CancelReason    string `json:"cancel_reason,omitempty"`
+	Platform        string `json:"platform,omitempty"`
+}
+
+// ServiceObserved records an observation of a service running on Date.
+type ServiceObserved struct {
+	Date        string      `json:"date"`
+	Observation Observation `json:"observation"`
+}
+
+// TubeStatusObserved records the Northern Line status seen at Time.
+type TubeStatusObserved struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Notified records that the alert identified by Type and Value was delivered,
+// so it is not repeated after a restart. Journey and ServiceUID are empty for
+// Northern Line alerts.
+type Notified struct {
+	Date       string `json:"date"`
+	Journey    string `json:"journey,omitempty"`
+	ServiceUID string `json:"service_uid,omitempty"`
+	Type       string `json:"type"`
+	Value      string `json:"value,omitempty"`
+}
+
+// DelayRepayClaim holds the details needed to claim Delay Repay for a late
+// arrival. For a journey with connections it covers the whole journey, from
+// the first leg's booked departure to the arrival at the end of the last.
+type DelayRepayClaim struct {
+	Journey         string `json:"journey"`
+	ServiceUID      string `json:"service_uid"` // the service arriving at To
+	RunDate         string `json:"run_date"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	BookedDeparture string `json:"booked_departure"`
+	BookedArrival   string `json:"booked_arrival"`
+	ActualArrival   string `json:"actual_arrival"`
+	DelayMinutes    int    `json:"delay_minutes"`
+	Band            int    `json:"band"`
+}
+
+// DelayRepayClaimed records a journey arriving late enough to claim Delay
+// Repay on Date, whether or not the alert about it is delivered.
+type DelayRepayClaimed struct {
+	Date  string          `json:"date"`
+	Claim DelayRepayClaim `json:"claim"`
+}
+
+func (ServiceObserved) Kind() string    { return KindServiceObserved }
+func (TubeStatusObserved) Kind() string { return KindTubeStatusObserved }
+func (Notified) Kind() string           { return KindNotified }
+func (DelayRepayClaimed) Kind() string  { return KindDelayRepayClaimed }
+
+func (ServiceObserved) record()    {}
+func (TubeStatusObserved) record() {}
+func (Notified) record()           {}
+func (DelayRepayClaimed) record()  {}
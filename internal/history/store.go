@@ -0,0 +1,485 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// DateFormat is the layout of the dates used to key services by day, matching
+// the RTT runDate field.
+const DateFormat = "2006-01-02"
+
+// Entry kinds.
+const (
+	KindService      = "service"
+	KindNotification = "notification"
+	KindTube         = "tube"
+	KindDelayRepay   = "delay_repay"
+)
+
+// Service is everything recorded about one monitored service on one day.
+type Service struct {
+	Date            string         `json:"date"`
+	Journey         string         `json:"journey"`
+	ServiceUID      string         `json:"service_uid"`
+	From            string         `json:"from"`
+	To              string         `json:"to"`
+	BookedDeparture string         `json:"booked_departure,omitempty"`
+	ActualDeparture string         `json:"actual_departure,omitempty"`
+	BookedArrival   string         `json:"booked_arrival,omitempty"`
+	ActualArrival   string         `json:"actual_arrival,omitempty"`
+	Departed        bool           `json:"departed,omitempty"`
+	Arrived         bool           `json:"arrived,omitempty"`
+	MaxDelay        int            `json:"max_delay"`
+	ArrivalDelay    int            `json:"arrival_delay"`
+	JourneyDelay    int            `json:"journey_delay,omitempty"`
+	Cancelled       bool           `json:"cancelled,omitempty"`
+	CancelReason    string         `json:"cancel_reason,omitempty"`
+	Platform        string         `json:"platform,omitempty"`
+	Notifications   []Notification `json:"notifications,omitempty"`
+}
+
+// Observation is what a monitor saw of a service during one check. Empty
+// fields leave the recorded values unchanged.
+type Observation = events.Observation
+
+// Notification is a notification that was sent. Type and Value identify it
+// for deduplication, e.g. Type "delay" with Value "10" for the 10 minute bucket.
+type Notification struct {
+	Time       time.Time `json:"time"`
+	Journey    string    `json:"journey,omitempty"`
+	ServiceUID string    `json:"service_uid,omitempty"`
+	Type       string    `json:"type"`
+	Value      string    `json:"value,omitempty"`
+}
+
+// TubeStatus is a recorded Northern Line status.
+type TubeStatus struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// DelayRepayClaim is a journey that arrived late enough to claim Delay Repay.
+type DelayRepayClaim = events.DelayRepayClaim
+
+// entry is a single line in the history file.
+type entry struct {
+	Time         time.Time        `json:"time"`
+	Kind         string           `json:"kind"`
+	Date         string           `json:"date"`
+	Observation  *Observation     `json:"observation,omitempty"`
+	Notification *Notification    `json:"notification,omitempty"`
+	Tube         *TubeStatus      `json:"tube,omitempty"`
+	Claim        *DelayRepayClaim `json:"claim,omitempty"`
+}
+
+// Store is an append-only JSONL journey history. Every entry is written to
+// the file as it is recorded and the whole file is replayed into memory when
+// the store is opened.
+//
+// A nil *Store is valid and records nothing, so monitors can run without history.
+type Store struct {
+	mu            sync.Mutex
+	file          *os.File
+	services      map[string]map[string]*Service // date -> service uid -> service
+	notifications map[string][]Notification      // date -> notifications
+	tube          map[string][]TubeStatus        // date -> statuses
+	claims        map[string][]DelayRepayClaim   // date -> claims
+}
+
+// Open opens the history file at path, creating it if it does not exist.
+//
+// A final line that cannot be parsed was torn by a crash mid-write, and is
+// logged and truncated away; a final entry missing only its newline is kept
+// and the newline written. A bad line anywhere else fails.
+func Open(path string, logger *logrus.Logger) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening history file: %w", err)
+	}
+
+	s := &Store{
+		file:          file,
+		services:      make(map[string]map[string]*Service),
+		notifications: make(map[string][]Notification),
+		tube:          make(map[string][]TubeStatus),
+		claims:        make(map[string][]DelayRepayClaim),
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64 // the end of the last good line
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			file.Close()
+			return nil, fmt.Errorf("reading history file: %w", readErr)
+		}
+
+		var e entry
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			if err := json.Unmarshal(trimmed, &e); err != nil {
+				if _, peekErr := reader.Peek(1); readErr == nil && peekErr == nil {
+					file.Close()
+					return nil, fmt.Errorf("parsing history line %d: %w", line, err)
+				}
+				logger.WithFields(logrus.Fields{
+					"line":  line,
+					"error": err,
+				}).Warn("truncating torn final line of history file")
+				if err := file.Truncate(offset); err != nil {
+					file.Close()
+					return nil, fmt.Errorf("truncating history file: %w", err)
+				}
+				break
+			}
+			s.apply(e)
+			if readErr != nil {
+				// The final entry is whole but its newline was never written, so
+				// finish the line before anything is appended after it.
+				logger.WithField("line", line).Warn("completing final line of history file")
+				if _, err := file.Write([]byte{'\n'}); err != nil {
+					file.Close()
+					return nil, fmt.Errorf("completing history file: %w", err)
+				}
+			}
+		}
+		offset += int64(len(data))
+		if readErr != nil {
+			break
+		}
+	}
+
+	return s, nil
+}
+
+// Close closes the history file.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// RecordService merges an observation of a service into its record for the
+// given date. Nothing is written if the observation changes nothing.
+func (s *Store) RecordService(date string, obs Observation) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if svc := s.services[date][obs.ServiceUID]; svc != nil {
+		merged := *svc
+		mergeObservation(&merged, obs)
+		if equalService(&merged, svc) {
+			return nil
+		}
+	}
+
+	return s.write(entry{Time: time.Now(), Kind: KindService, Date: date, Observation: &obs})
+}
+
+// RecordNotification records a notification sent on the given date.
+func (s *Store) RecordNotification(date string, n Notification) error {
+	if s == nil {
+		return nil
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(entry{Time: n.Time, Kind: KindNotification, Date: date, Notification: &n})
+}
+
+// RecordTubeStatus records a Northern Line status seen at now. It is only
+// written if it differs from the last status recorded that day.
+func (s *Store) RecordTubeStatus(now time.Time, status, reason string) error {
+	if s == nil {
+		return nil
+	}
+	date := now.Format(DateFormat)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if statuses := s.tube[date]; len(statuses) > 0 {
+		last := statuses[len(statuses)-1]
+		if last.Status == status && last.Reason == reason {
+			return nil
+		}
+	}
+	ts := TubeStatus{Time: now, Status: status, Reason: reason}
+	return s.write(entry{Time: now, Kind: KindTube, Date: date, Tube: &ts})
+}
+
+// RecordDelayRepayClaim records a Delay Repay claim for a journey on the
+// given date. A claim already recorded for the same journey and service is
+// replaced, and nothing is written if it is unchanged.
+func (s *Store) RecordDelayRepayClaim(date string, claim DelayRepayClaim) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.claims[date] {
+		if c == claim {
+			return nil
+		}
+	}
+	return s.write(entry{Time: time.Now(), Kind: KindDelayRepay, Date: date, Claim: &claim})
+}
+
+// Handle records the events that update the journey history, so the store can
+// subscribe to an events.Bus. Other events are ignored.
+func (s *Store) Handle(e events.Event) error {
+	switch e := e.(type) {
+	case events.ServiceObserved:
+		return s.RecordService(e.Date, e.Observation)
+	case events.TubeStatusObserved:
+		return s.RecordTubeStatus(e.Time, e.Status, e.Reason)
+	case events.DelayRepayClaimed:
+		return s.RecordDelayRepayClaim(e.Date, e.Claim)
+	case events.Notified:
+		return s.RecordNotification(e.Date, Notification{
+			Journey:    e.Journey,
+			ServiceUID: e.ServiceUID,
+			Type:       e.Type,
+			Value:      e.Value,
+		})
+	}
+	return nil
+}
+
+// Services returns the services recorded between from and to inclusive,
+// ordered by date and booked departure.
+func (s *Store) Services(from, to time.Time) []Service {
+	if s == nil {
+		return nil
+	}
+	fromDate, toDate := from.Format(DateFormat), to.Format(DateFormat)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Service
+	for date, services := range s.services {
+		if date < fromDate || date > toDate {
+			continue
+		}
+		for _, svc := range services {
+			c := *svc
+			c.Notifications = append([]Notification(nil), svc.Notifications...)
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].BookedDeparture < result[j].BookedDeparture
+	})
+	return result
+}
+
+// Service returns the service recorded on the given date, if there is one.
+func (s *Store) Service(date, uid string) (Service, bool) {
+	if s == nil {
+		return Service{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.services[date][uid]
+	if svc == nil {
+		return Service{}, false
+	}
+	c := *svc
+	c.Notifications = append([]Notification(nil), svc.Notifications...)
+	return c, true
+}
+
+// Notifications returns the notifications recorded on the given date.
+func (s *Store) Notifications(date string) []Notification {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Notification(nil), s.notifications[date]...)
+}
+
+// DelayRepayClaims returns the claims recorded between from and to inclusive,
+// ordered by date and booked departure.
+func (s *Store) DelayRepayClaims(from, to time.Time) []DelayRepayClaim {
+	if s == nil {
+		return nil
+	}
+	fromDate, toDate := from.Format(DateFormat), to.Format(DateFormat)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []DelayRepayClaim
+	for date, claims := range s.claims {
+		if date >= fromDate && date <= toDate {
+			result = append(result, claims...)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RunDate != result[j].RunDate {
+			return result[i].RunDate < result[j].RunDate
+		}
+		return result[i].BookedDeparture < result[j].BookedDeparture
+	})
+	return result
+}
+
+// LastTubeStatus returns the last Northern Line status recorded on the given date.
+func (s *Store) LastTubeStatus(date string) (TubeStatus, bool) {
+	if s == nil {
+		return TubeStatus{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := s.tube[date]
+	if len(statuses) == 0 {
+		return TubeStatus{}, false
+	}
+	return statuses[len(statuses)-1], true
+}
+
+// write appends an entry to the file and applies it in memory. The caller
+// must hold s.mu.
+func (s *Store) write(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding history entry: %w", err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing history entry: %w", err)
+	}
+	s.apply(e)
+	return nil
+}
+
+func (s *Store) apply(e entry) {
+	switch e.Kind {
+	case KindService:
+		if e.Observation == nil {
+			return
+		}
+		mergeObservation(s.service(e.Date, e.Observation.ServiceUID), *e.Observation)
+
+	case KindNotification:
+		if e.Notification == nil {
+			return
+		}
+		n := *e.Notification
+		s.notifications[e.Date] = append(s.notifications[e.Date], n)
+		if n.ServiceUID != "" {
+			svc := s.service(e.Date, n.ServiceUID)
+			svc.Notifications = append(svc.Notifications, n)
+			if svc.Journey == "" {
+				svc.Journey = n.Journey
+			}
+		}
+
+	case KindTube:
+		if e.Tube == nil {
+			return
+		}
+		s.tube[e.Date] = append(s.tube[e.Date], *e.Tube)
+
+	case KindDelayRepay:
+		if e.Claim == nil {
+			return
+		}
+		claims := s.claims[e.Date]
+		for i, c := range claims {
+			if c.Journey == e.Claim.Journey && c.ServiceUID == e.Claim.ServiceUID {
+				claims[i] = *e.Claim
+				return
+			}
+		}
+		s.claims[e.Date] = append(claims, *e.Claim)
+	}
+}
+
+func (s *Store) service(date, uid string) *Service {
+	services := s.services[date]
+	if services == nil {
+		services = make(map[string]*Service)
+		s.services[date] = services
+	}
+	svc := services[uid]
+	if svc == nil {
+		svc = &Service{Date: date, ServiceUID: uid}
+		services[uid] = svc
+	}
+	return svc
+}
+
+func mergeObservation(svc *Service, obs Observation) {
+	setIfSet := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	setIfSet(&svc.Journey, obs.Journey)
+	setIfSet(&svc.From, obs.From)
+	setIfSet(&svc.To, obs.To)
+	setIfSet(&svc.BookedDeparture, obs.BookedDeparture)
+	setIfSet(&svc.ActualDeparture, obs.ActualDeparture)
+	setIfSet(&svc.BookedArrival, obs.BookedArrival)
+	setIfSet(&svc.ActualArrival, obs.ActualArrival)
+	setIfSet(&svc.CancelReason, obs.CancelReason)
+	setIfSet(&svc.Platform, obs.Platform)
+	svc.Departed = svc.Departed || obs.Departed
+	svc.Arrived = svc.Arrived || obs.Arrived
+	svc.Cancelled = svc.Cancelled || obs.Cancelled
+	if obs.DelayMinutes > svc.MaxDelay {
+		svc.MaxDelay = obs.DelayMinutes
+	}
+	if obs.ArrivalDelay > svc.MaxDelay {
+		svc.MaxDelay = obs.ArrivalDelay
+	}
+	if obs.Arrived || obs.ArrivalDelay > 0 {
+		svc.ArrivalDelay = obs.ArrivalDelay
+	}
+	if obs.Arrived {
+		svc.JourneyDelay = obs.JourneyDelay
+	}
+}
+
+func equalService(a, b *Service) bool {
+	return a.Journey == b.Journey &&
+		a.From == b.From &&
+		a.To == b.To &&
+		a.BookedDeparture == b.BookedDeparture &&
+		a.ActualDeparture == b.ActualDeparture &&
+		a.BookedArrival == b.BookedArrival &&
+		a.ActualArrival == b.ActualArrival &&
+		a.Departed == b.Departed &&
+		a.Arrived == b.Arrived &&
+		a.MaxDelay == b.MaxDelay &&
+		a.ArrivalDelay == b.ArrivalDelay &&
+		a.JourneyDelay == b.JourneyDelay &&
+		a.Cancelled == b.Cancelled &&
+		a.CancelReason == b.CancelReason &&
+		a.Platform == b.Platform
+}
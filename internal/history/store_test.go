@@ -0,0 +1,182 @@
+package history
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+const testDate = "2025-01-06"
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// openTest opens a store at path, closing it when the test ends.
+func openTest(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path, testLogger())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// writeHistory writes a history file with a departure and an arrival of
+// W12345 and returns its path and contents.
+func writeHistory(t *testing.T) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, testLogger())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	observations := []Observation{
+		{Journey: "morning", ServiceUID: "W12345", From: "WIN", To: "WAT", BookedDeparture: "0800", ActualDeparture: "0805", Departed: true, DelayMinutes: 5},
+		{Journey: "morning", ServiceUID: "W12345", From: "WIN", To: "WAT", BookedArrival: "0900", ActualArrival: "0912", Arrived: true, ArrivalDelay: 12},
+	}
+	for _, obs := range observations {
+		if err := s.RecordService(testDate, obs); err != nil {
+			t.Fatalf("RecordService() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, string(data)
+}
+
+func wantArrived(t *testing.T, s *Store) {
+	t.Helper()
+	svc, ok := s.Service(testDate, "W12345")
+	if !ok {
+		t.Fatal("service W12345 not found")
+	}
+	if !svc.Departed || svc.ActualDeparture != "0805" {
+		t.Errorf("departure = %+v, want departed at 0805", svc)
+	}
+	if !svc.Arrived || svc.ActualArrival != "0912" || svc.ArrivalDelay != 12 || svc.MaxDelay != 12 {
+		t.Errorf("arrival = %+v, want arrived at 0912, 12 minutes late", svc)
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	path, _ := writeHistory(t)
+	s := openTest(t, path)
+	wantArrived(t, s)
+
+	if err := s.RecordNotification(testDate, Notification{Journey: "morning", ServiceUID: "W12345", Type: "arrival", Value: "0912"}); err != nil {
+		t.Fatalf("RecordNotification() error = %v", err)
+	}
+	s.Close()
+
+	s = openTest(t, path)
+	wantArrived(t, s)
+	if n := s.Notifications(testDate); len(n) != 1 || n[0].Type != "arrival" || n[0].Value != "0912" {
+		t.Errorf("Notifications() = %+v, want the arrival notification", n)
+	}
+}
+
+func TestStoreTornFinalLine(t *testing.T) {
+	path, data := writeHistory(t)
+	if err := os.WriteFile(path, []byte(data+`{"time":"2025-01-06T09:15:00Z","kind":"serv`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTest(t, path)
+	wantArrived(t, s)
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("file after open = %q, want the torn line truncated to %q", got, data)
+	}
+}
+
+func TestStoreFinalLineWithoutNewline(t *testing.T) {
+	path, data := writeHistory(t)
+	if err := os.WriteFile(path, []byte(strings.TrimSuffix(data, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTest(t, path)
+	wantArrived(t, s)
+
+	// Appending after the completed line leaves a file that opens again.
+	if err := s.RecordNotification(testDate, Notification{Journey: "morning", ServiceUID: "W12345", Type: "arrival", Value: "0912"}); err != nil {
+		t.Fatalf("RecordNotification() error = %v", err)
+	}
+	s.Close()
+
+	s = openTest(t, path)
+	wantArrived(t, s)
+	if n := s.Notifications(testDate); len(n) != 1 {
+		t.Errorf("Notifications() = %+v, want 1", n)
+	}
+}
+
+func TestStoreCorruptLine(t *testing.T) {
+	path, data := writeHistory(t)
+	lines := strings.SplitAfter(data, "\n")
+	corrupt := lines[0] + "not json\n" + strings.Join(lines[1:], "")
+	if err := os.WriteFile(path, []byte(corrupt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := Open(path, testLogger()); err == nil {
+		s.Close()
+		t.Fatal("Open() with a corrupt line mid-file succeeded")
+	}
+}
+
+func TestStoreDelayRepayClaims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := openTest(t, path)
+
+	claim := DelayRepayClaim{
+		Journey: "morning", ServiceUID: "W12345", RunDate: testDate,
+		From: "WIN", To: "WAT", BookedDeparture: "0800",
+		BookedArrival: "0900", ActualArrival: "0920", DelayMinutes: 20, Band: 15,
+	}
+	// Recording the claim again after a restart writes nothing new.
+	for range 2 {
+		if err := s.Handle(events.DelayRepayClaimed{Date: testDate, Claim: claim}); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	s.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 1 {
+		t.Errorf("history file has %d lines, want 1", n)
+	}
+
+	s = openTest(t, path)
+	day, _ := time.ParseInLocation(DateFormat, testDate, time.Local)
+	want := []DelayRepayClaim{claim}
+	if got := s.DelayRepayClaims(day, day); !slices.Equal(got, want) {
+		t.Errorf("DelayRepayClaims() = %+v, want %+v", got, want)
+	}
+	if got := s.DelayRepayClaims(day.AddDate(0, 0, 1), day.AddDate(0, 0, 7)); len(got) != 0 {
+		t.Errorf("DelayRepayClaims() for the next week = %+v, want none", got)
+	}
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// delayAlternatives returns alternatives for a delayed service if the delay has
+// reached the journey's threshold.
+func (m *TrainMonitor) delayAlternatives(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg, delayMins int) []events.Alternative {
+	threshold := journey.Alternatives.DelayThreshold
+	if threshold <= 0 || delayMins < threshold {
+		return nil
+	}
+	return m.alternatives(ctx, svc, journey, leg)
+}
+
+// alternatives returns the best other services for the leg, logging rather than
+// failing so that the alert itself is still sent.
+func (m *TrainMonitor) alternatives(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg) []events.Alternative {
+	alts, err := m.findAlternatives(ctx, leg, svc.ServiceUid, journey.Alternatives.Limit())
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"service": svc.ServiceUid,
+			"error":   err,
+		}).Warn("failed to find alternative services")
+		return nil
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"service":      svc.ServiceUid,
+		"alternatives": len(alts),
+	}).Debug("found alternative services")
+	return alts
+}
+
+// findAlternatives searches for services between the leg's stations departing
+// from its booked time onwards, drops cancelled ones and the excluded service,
+// and returns up to limit ranked by expected arrival at the destination.
+func (m *TrainMonitor) findAlternatives(ctx context.Context, leg config.Leg, excludeUID string, limit int) ([]events.Alternative, error) {
+	depTime, err := m.parseTimeToday(leg.Departure)
+	if err != nil {
+		return nil, fmt.Errorf("parsing departure time: %w", err)
+	}
+
+	resp, err := m.rttClient.Search(ctx, leg.From, leg.To, depTime)
+	if err != nil {
+		return nil, fmt.Errorf("searching for alternatives: %w", err)
+	}
+	if resp == nil {
+		return nil, nil
+	}
+
+	type candidate struct {
+		alt     events.Alternative
+		arrival time.Time
+	}
+
+	// Look a little beyond the limit, as a later departure can arrive first.
+	maxCandidates := limit * 2
+	var candidates []candidate
+	for i := range resp.Services {
+		if len(candidates) >= maxCandidates {
+			break
+		}
+		svc := &resp.Services[i]
+		if svc.ServiceUid == excludeUID || isCancelled(&svc.LocationDetail) {
+			continue
+		}
+		dep, err := serviceDeparture(svc, depTime)
+		if err != nil || dep.Before(depTime) {
+			continue
+		}
+		arrival, err := m.serviceArrival(ctx, svc, leg.To, depTime)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			alt: events.Alternative{
+				ServiceUID: svc.ServiceUid,
+				Departure:  dep.Format("1504"),
+				Arrival:    arrival.Format("1504"),
+				Platform:   svc.LocationDetail.Platform,
+			},
+			arrival: arrival,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].arrival.Before(candidates[j].arrival)
+	})
+
+	var alts []events.Alternative
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		alts = append(alts, candidates[i].alt)
+	}
+	return alts, nil
+}
@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// arrivalEstimate returns the expected arrival of a service at a station and
+// how late that is against the booked arrival.
+func (m *TrainMonitor) arrivalEstimate(ctx context.Context, service *rtt.Service, to string, runDate time.Time) (*events.ArrivalEstimate, bool, error) {
+	details, err := m.rttClient.GetService(ctx, service.ServiceUid, runDate)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting service details: %w", err)
+	}
+
+	for _, loc := range details.Locations {
+		if loc.CRS != to {
+			continue
+		}
+		expected := loc.RealtimeArrival
+		if expected == "" {
+			expected = loc.GbttBookedArrival
+		}
+		if expected == "" {
+			return nil, false, fmt.Errorf("no arrival time found for destination")
+		}
+
+		var delayMins int
+		if loc.RealtimeArrival != "" && loc.GbttBookedArrival != "" {
+			delayMins = m.calculateDelay(loc.GbttBookedArrival, loc.RealtimeArrival)
+		}
+		return &events.ArrivalEstimate{
+			Station:      to,
+			Booked:       loc.GbttBookedArrival,
+			Expected:     expected,
+			DelayMinutes: delayMins,
+		}, loc.RealtimeArrivalActual, nil
+	}
+
+	return nil, false, fmt.Errorf("destination %s not found in service", to)
+}
+
+// expectedArrivalAt returns the arrival estimate for a notification, or nil if
+// it cannot be determined.
+func (m *TrainMonitor) expectedArrivalAt(ctx context.Context, svc *rtt.Service, to string) *events.ArrivalEstimate {
+	estimate, _, err := m.arrivalEstimate(ctx, svc, to, m.clock.Now())
+	if err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"service": svc.ServiceUid,
+			"to":      to,
+			"error":   err,
+		}).Debug("no arrival estimate available")
+		return nil
+	}
+	return estimate
+}
+
+// CheckArrivalDelay checks the expected arrival delay of a train en route and
+// notifies each time it crosses a higher configured threshold. It reports
+// done once there is nothing left to check: the train has reached its
+// destination, been cancelled, or it is past the booked arrival plus the
+// largest threshold, by when every threshold the train can still cross has
+// been crossed.
+func (m *TrainMonitor) CheckArrivalDelay(ctx context.Context, journey *config.TrainConfig, leg config.Leg) (done bool, err error) {
+	depTime, err := m.parseTimeToday(leg.Departure)
+	if err != nil {
+		return false, fmt.Errorf("parsing departure time: %w", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"from":      leg.From,
+		"to":        leg.To,
+		"departure": leg.Departure,
+	}).Info("checking arrival delay")
+
+	resp, err := m.rttClient.Search(ctx, leg.From, leg.To, depTime)
+	if err != nil {
+		return false, fmt.Errorf("searching for train: %w", err)
+	}
+	if resp == nil || len(resp.Services) == 0 {
+		return false, nil
+	}
+
+	service := m.findMatchingService(resp.Services, leg.Departure)
+	if service == nil {
+		return false, nil
+	}
+	if isCancelled(&service.LocationDetail) {
+		return true, nil
+	}
+
+	estimate, arrived, err := m.arrivalEstimate(ctx, service, leg.To, depTime)
+	if err != nil {
+		return false, err
+	}
+
+	m.observe(service, events.Observation{
+		Journey:       journey.Name,
+		From:          leg.From,
+		To:            leg.To,
+		BookedArrival: estimate.Booked,
+		ArrivalDelay:  estimate.DelayMinutes,
+	})
+
+	if arrived {
+		return true, nil
+	}
+
+	threshold := crossedThreshold(journey.ArrivalAlerts, estimate.DelayMinutes)
+	done = m.pastLastThreshold(journey, estimate, depTime)
+
+	m.mu.Lock()
+	shouldNotify := threshold > m.notifiedArrivalDelays[service.ServiceUid]
+	if shouldNotify {
+		m.notifiedArrivalDelays[service.ServiceUid] = threshold
+	}
+	m.mu.Unlock()
+
+	if !shouldNotify {
+		return done, nil
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"service":       service.ServiceUid,
+		"delay_minutes": estimate.DelayMinutes,
+		"threshold":     threshold,
+		"expected":      estimate.Expected,
+	}).Warn("arrival delay threshold crossed")
+
+	err = m.publish(events.ArrivalDelayed{
+		Service: m.eventService(journey, service, leg.From, leg.To),
+		Arrival: *estimate,
+	}, journey, service, notifyArrivalDelay, strconv.Itoa(threshold))
+	if err != nil {
+		return done, err
+	}
+	return done, nil
+}
+
+// pastLastThreshold reports whether it is past the booked arrival plus the
+// journey's largest arrival alert threshold.
+func (m *TrainMonitor) pastLastThreshold(journey *config.TrainConfig, estimate *events.ArrivalEstimate, depTime time.Time) bool {
+	booked, err := m.parseTimeToday(estimate.Booked)
+	if err != nil {
+		return false
+	}
+	if booked.Before(depTime) {
+		booked = booked.AddDate(0, 0, 1)
+	}
+	last := crossedThreshold(journey.ArrivalAlerts, math.MaxInt)
+	return !m.clock.Now().Before(booked.Add(time.Duration(last) * time.Minute))
+}
+
+// crossedThreshold returns the highest threshold that delayMins has reached,
+// or 0 if none.
+func crossedThreshold(thresholds []int, delayMins int) int {
+	var crossed int
+	for _, t := range thresholds {
+		if delayMins >= t && t > crossed {
+			crossed = t
+		}
+	}
+	return crossed
+}
@@ -0,0 +1,261 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// Connection states, ordered by severity so that an alert is only repeated
+// when the situation gets worse.
+const (
+	connectionOK = iota
+	connectionAtRisk
+	connectionMissed
+)
+
+// CheckConnection compares the expected arrival of the feeder leg at the
+// interchange with the expected departure of the next leg, and alerts when
+// the connection is at risk or will be missed, as it is if the feeder is
+// cancelled. It reports done once the connecting train has departed or is
+// due to, or if it cannot be found, once its booked departure has passed.
+func (m *TrainMonitor) CheckConnection(ctx context.Context, journey *config.TrainConfig, feeder, next config.Leg) (done bool, err error) {
+	station := next.From
+
+	m.logger.WithFields(logrus.Fields{
+		"station":  station,
+		"arriving": feeder.Departure,
+		"departs":  next.Departure,
+	}).Info("checking connection")
+
+	arrival, feederSvc, err := m.expectedArrival(ctx, feeder.From, feeder.To, feeder.Departure)
+	feederCancelled := feederSvc != nil && isCancelled(&feederSvc.LocationDetail)
+	if err != nil && !feederCancelled {
+		return false, fmt.Errorf("getting feeder arrival: %w", err)
+	}
+
+	nextSvc, departure, err := m.expectedDeparture(ctx, next.From, next.To, next.Departure)
+	if err != nil {
+		return false, fmt.Errorf("getting connecting departure: %w", err)
+	}
+	if nextSvc == nil {
+		m.logger.WithField("departure", next.Departure).Warn("no matching service found for connection")
+		booked, err := m.parseTimeToday(next.Departure)
+		if err != nil {
+			return true, fmt.Errorf("parsing departure time: %w", err)
+		}
+		return !m.clock.Now().Before(booked), nil
+	}
+	done = !m.clock.Now().Before(departure)
+
+	slack := departure.Sub(arrival)
+	state := connectionOK
+	switch {
+	case feederCancelled || isCancelled(&nextSvc.LocationDetail) || slack < 0:
+		state = connectionMissed
+	case slack < next.ConnectionTime():
+		state = connectionAtRisk
+	}
+
+	fields := logrus.Fields{
+		"station":    station,
+		"feeder":     feederSvc.ServiceUid,
+		"connection": nextSvc.ServiceUid,
+		"departure":  departure.Format("1504"),
+		"min_mins":   int(next.ConnectionTime().Minutes()),
+	}
+	var arrivalTime string
+	if feederCancelled {
+		fields["feeder_cancelled"] = true
+	} else {
+		arrivalTime = arrival.Format("1504")
+		fields["arrival"] = arrivalTime
+		fields["slack_mins"] = int(slack.Minutes())
+	}
+	logger := m.logger.WithFields(fields)
+
+	if state == connectionOK {
+		logger.Info("connection ok")
+		return done, nil
+	}
+
+	key := feederSvc.ServiceUid + "->" + nextSvc.ServiceUid
+	m.mu.Lock()
+	shouldNotify := state > m.notifiedConnections[key]
+	if shouldNotify {
+		m.notifiedConnections[key] = state
+	}
+	m.mu.Unlock()
+
+	if !shouldNotify {
+		logger.Debug("connection state already notified")
+		return done, nil
+	}
+
+	// With the feeder cancelled there is no arrival to find a later
+	// connection from.
+	var alt *events.Alternative
+	if !feederCancelled {
+		alt, err = m.nextConnection(ctx, next, arrival.Add(next.ConnectionTime()), nextSvc.ServiceUid)
+		if err != nil {
+			logger.WithField("error", err).Warn("failed to find next viable connection")
+		}
+	}
+
+	var e events.Event
+	if state == connectionMissed {
+		logger.Warn("connection will be missed")
+		e = events.ConnectionMissed{
+			Service:         m.eventService(journey, nextSvc, next.From, next.To),
+			FeederUID:       feederSvc.ServiceUid,
+			FeederCancelled: feederCancelled,
+			ArrivalTime:     arrivalTime,
+			DepartureTime:   departure.Format("1504"),
+			Next:            alt,
+		}
+	} else {
+		logger.Warn("connection at risk")
+		e = events.ConnectionAtRisk{
+			Service:       m.eventService(journey, nextSvc, next.From, next.To),
+			FeederUID:     feederSvc.ServiceUid,
+			ArrivalTime:   arrivalTime,
+			DepartureTime: departure.Format("1504"),
+			SlackMinutes:  int(slack.Minutes()),
+			Next:          alt,
+		}
+	}
+	return done, m.publish(e, journey, nextSvc, notifyConnection, feederSvc.ServiceUid+":"+strconv.Itoa(state))
+}
+
+// expectedDeparture finds the service departing from at departureTime and returns it
+// with its expected departure time. The service is nil if none matches.
+func (m *TrainMonitor) expectedDeparture(ctx context.Context, from, to, departureTime string) (*rtt.Service, time.Time, error) {
+	depTime, err := m.parseTimeToday(departureTime)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing departure time: %w", err)
+	}
+
+	resp, err := m.rttClient.Search(ctx, from, to, depTime)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("searching for train: %w", err)
+	}
+	if resp == nil {
+		return nil, time.Time{}, nil
+	}
+
+	service := m.findMatchingService(resp.Services, departureTime)
+	if service == nil {
+		return nil, time.Time{}, nil
+	}
+
+	expected, err := serviceDeparture(service, depTime)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return service, expected, nil
+}
+
+// nextConnection returns the first service on the next leg that is not
+// cancelled and departs no earlier than notBefore, or nil if none is found.
+func (m *TrainMonitor) nextConnection(ctx context.Context, next config.Leg, notBefore time.Time, excludeUID string) (*events.Alternative, error) {
+	svc, dep, err := m.catchableService(ctx, next, notBefore, excludeUID)
+	if err != nil || svc == nil {
+		return nil, err
+	}
+
+	alt := &events.Alternative{
+		ServiceUID: svc.ServiceUid,
+		Departure:  dep.Format("1504"),
+		Platform:   svc.LocationDetail.Platform,
+	}
+	if arrival, err := m.serviceArrival(ctx, svc, next.To, notBefore); err == nil {
+		alt.Arrival = arrival.Format("1504")
+	}
+	return alt, nil
+}
+
+// catchableService returns the first service on a leg that is not cancelled
+// and departs no earlier than notBefore, with its expected departure, or nil
+// if none is found.
+func (m *TrainMonitor) catchableService(ctx context.Context, leg config.Leg, notBefore time.Time, excludeUID string) (*rtt.Service, time.Time, error) {
+	resp, err := m.rttClient.Search(ctx, leg.From, leg.To, notBefore)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("searching for connections: %w", err)
+	}
+	if resp == nil {
+		return nil, time.Time{}, nil
+	}
+
+	for i := range resp.Services {
+		svc := &resp.Services[i]
+		if svc.ServiceUid == excludeUID || isCancelled(&svc.LocationDetail) {
+			continue
+		}
+		dep, err := serviceDeparture(svc, notBefore)
+		if err != nil || dep.Before(notBefore) {
+			continue
+		}
+		return svc, dep, nil
+	}
+	return nil, time.Time{}, nil
+}
+
+// takenService follows the journey's route up to leg, and returns the service
+// the traveller can expect to be on for that leg, along with the leg's booked
+// service. On each leg that is the booked service, unless it is cancelled or
+// departs before the previous leg arrives and the connection time has passed,
+// in which case it is the first later service that can be caught. Either
+// service is nil if it cannot be found.
+func (m *TrainMonitor) takenService(ctx context.Context, journey *config.TrainConfig, leg config.Leg) (*rtt.Service, *rtt.Service, error) {
+	route := journey.Route()
+	notBefore, err := m.parseTimeToday(route[0].Departure)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing departure time: %w", err)
+	}
+	for i, l := range route {
+		booked, dep, err := m.expectedDeparture(ctx, l.From, l.To, l.Departure)
+		if err != nil {
+			return nil, nil, err
+		}
+		taken := booked
+		if booked == nil || isCancelled(&booked.LocationDetail) || (i > 0 && dep.Before(notBefore)) {
+			taken, _, err = m.catchableService(ctx, l, notBefore, "")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if l == leg || taken == nil {
+			return taken, booked, nil
+		}
+
+		arrival, err := m.serviceArrival(ctx, taken, l.To, notBefore)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i+1 < len(route) {
+			notBefore = arrival.Add(route[i+1].ConnectionTime())
+		}
+	}
+	return nil, nil, fmt.Errorf("leg %s to %s not in journey %s", leg.From, leg.To, journey.Name)
+}
+
+// serviceDeparture returns the expected (or booked) departure time of a
+// search result on the same day as day.
+func serviceDeparture(svc *rtt.Service, day time.Time) (time.Time, error) {
+	depStr := svc.LocationDetail.RealtimeDeparture
+	if depStr == "" {
+		depStr = svc.LocationDetail.GbttBookedDeparture
+	}
+	t, err := parseHHMM(depStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing departure time: %w", err)
+	}
+	return onDay(day, t), nil
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// delayRepayBands are the arrival delay thresholds in minutes at which Delay
+// Repay compensation is paid, highest first.
+var delayRepayBands = []int{120, 60, 30, 15}
+
+// maxDelayRepayClaims is how many claims are kept in memory, the oldest being
+// dropped first. Every claim is kept in the journey history.
+const maxDelayRepayClaims = 50
+
+// DelayRepayClaim holds the details needed to claim Delay Repay for a late
+// arrival.
+type DelayRepayClaim = events.DelayRepayClaim
+
+// DelayRepayBand returns the Delay Repay band for an arrival delay, or 0 if
+// the delay is not eligible.
+func DelayRepayBand(delayMins int) int {
+	for _, band := range delayRepayBands {
+		if delayMins >= band {
+			return band
+		}
+	}
+	return 0
+}
+
+// DelayRepayClaims returns the most recent eligible claims, including those
+// restored from the journey history.
+func (m *TrainMonitor) DelayRepayClaims() []DelayRepayClaim {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	claims := make([]DelayRepayClaim, len(m.delayRepayClaims))
+	copy(claims, m.delayRepayClaims)
+	return claims
+}
+
+// recordDelayRepay classifies the delay of a journey's arrival at its
+// destination on svc against the booked arrival of its last leg and, if it is
+// eligible, records the claim in the journey history and notifies. The claim
+// is recorded even if the notification fails.
+func (m *TrainMonitor) recordDelayRepay(journey *config.TrainConfig, svc *rtt.Service, bookedArrival, actualArrival string) error {
+	if bookedArrival == "" || actualArrival == "" {
+		return nil
+	}
+
+	delayMins := m.calculateDelay(bookedArrival, actualArrival)
+	band := DelayRepayBand(delayMins)
+	if band == 0 {
+		return nil
+	}
+
+	route := journey.Route()
+	claim := DelayRepayClaim{
+		Journey:         journey.Name,
+		ServiceUID:      svc.ServiceUid,
+		RunDate:         svc.RunDate,
+		From:            route[0].From,
+		To:              route[len(route)-1].To,
+		BookedDeparture: route[0].Departure,
+		BookedArrival:   bookedArrival,
+		ActualArrival:   actualArrival,
+		DelayMinutes:    delayMins,
+		Band:            band,
+	}
+
+	m.mu.Lock()
+	m.addDelayRepayClaims(claim)
+	m.mu.Unlock()
+	if err := m.publisher.Publish(events.DelayRepayClaimed{Date: m.runDate(svc), Claim: claim}, nil); err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"service": svc.ServiceUid,
+			"error":   err,
+		}).Warn("failed to record delay repay claim")
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"service":       claim.ServiceUID,
+		"run_date":      claim.RunDate,
+		"delay_minutes": delayMins,
+		"band":          band,
+	}).Info("eligible for delay repay")
+
+	err := m.publish(events.DelayRepayEligible{
+		Service:         m.eventService(journey, svc, claim.From, claim.To),
+		BookedDeparture: claim.BookedDeparture,
+		BookedArrival:   claim.BookedArrival,
+		ActualArrival:   claim.ActualArrival,
+		DelayMinutes:    claim.DelayMinutes,
+		Band:            claim.Band,
+	}, journey, svc, notifyDelayRepay, strconv.Itoa(band))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// addDelayRepayClaims adds claims to those kept, dropping the oldest beyond
+// maxDelayRepayClaims. The caller must hold m.mu.
+func (m *TrainMonitor) addDelayRepayClaims(claims ...DelayRepayClaim) {
+	m.delayRepayClaims = append(m.delayRepayClaims, claims...)
+	if n := len(m.delayRepayClaims); n > maxDelayRepayClaims {
+		m.delayRepayClaims = append([]DelayRepayClaim(nil), m.delayRepayClaims[n-maxDelayRepayClaims:]...)
+	}
+}
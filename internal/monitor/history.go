@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// Notification types recorded in the journey history.
+const (
+	notifyDelay        = "delay"
+	notifyStatus       = "status"
+	notifyCancellation = "cancellation"
+	notifyPlatform     = "platform"
+	notifyDeparture    = "departure"
+	notifyArrival      = "arrival"
+	notifyArrivalDelay = "arrival_delay"
+	notifyConnection   = "connection"
+	notifyDelayRepay   = "delay_repay"
+	notifyTube         = "tube_disruption"
+	notifyTubeSummary  = "tube_summary"
+)
+
+// RestoreNotificationState rebuilds today's deduplication state from the
+// journey history, so a restart does not repeat alerts already sent, and
+// reloads the Delay Repay claims recorded.
+func (m *TrainMonitor) RestoreNotificationState() {
+	now := m.clock.Now()
+	notifications := m.history.Notifications(now.Format(history.DateFormat))
+	claims := m.history.DelayRepayClaims(time.Time{}, now)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delayRepayClaims = nil
+	m.addDelayRepayClaims(claims...)
+
+	for _, n := range notifications {
+		switch n.Type {
+		case notifyDelay:
+			if bucket, err := strconv.Atoi(n.Value); err == nil && bucket > m.notifiedDelays[n.ServiceUID] {
+				m.notifiedDelays[n.ServiceUID] = bucket
+			}
+		case notifyCancellation:
+			m.notifiedCancels[n.ServiceUID] = true
+		case notifyDeparture:
+			m.notifiedDepartures[n.ServiceUID] = true
+		case notifyPlatform:
+			m.notifiedPlatforms[n.ServiceUID] = n.Value
+		case notifyArrivalDelay:
+			if threshold, err := strconv.Atoi(n.Value); err == nil && threshold > m.notifiedArrivalDelays[n.ServiceUID] {
+				m.notifiedArrivalDelays[n.ServiceUID] = threshold
+			}
+		case notifyConnection:
+			// Value is "<feeder uid>:<state>"
+			feederUID, value, _ := strings.Cut(n.Value, ":")
+			key := feederUID + "->" + n.ServiceUID
+			if state, err := strconv.Atoi(value); err == nil && state > m.notifiedConnections[key] {
+				m.notifiedConnections[key] = state
+			}
+		}
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"notifications":      len(notifications),
+		"delay_repay_claims": len(claims),
+	}).Debug("restored notification state from history")
+}
+
+// observe records what was seen of a service in the journey history.
+func (m *TrainMonitor) observe(svc *rtt.Service, obs events.Observation) {
+	obs.ServiceUID = svc.ServiceUid
+	if err := m.publisher.Publish(events.ServiceObserved{Date: m.runDate(svc), Observation: obs}, nil); err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"service": svc.ServiceUid,
+			"error":   err,
+		}).Warn("failed to record service history")
+	}
+}
+
+// observeSearch records the origin details of a search result.
+func (m *TrainMonitor) observeSearch(journey *config.TrainConfig, leg config.Leg, svc *rtt.Service) {
+	detail := &svc.LocationDetail
+	obs := events.Observation{
+		Journey:         journey.Name,
+		From:            leg.From,
+		To:              leg.To,
+		BookedDeparture: detail.GbttBookedDeparture,
+		ActualDeparture: detail.RealtimeDeparture,
+		Platform:        detail.Platform,
+	}
+	if isCancelled(detail) {
+		obs.Cancelled = true
+		obs.CancelReason = detail.CancelReasonShortText
+	} else if detail.RealtimeDeparture != "" && detail.GbttBookedDeparture != "" {
+		obs.DelayMinutes = m.calculateDelay(detail.GbttBookedDeparture, detail.RealtimeDeparture)
+	}
+	m.observe(svc, obs)
+}
+
+// Publisher queues the monitors' events for delivery. Once an event has been
+// delivered, sent (if set) is published so the journey history records it,
+// and alerts that never went out are not treated as sent after a restart.
+type Publisher interface {
+	Publish(e events.Event, sent *events.Notified) error
+}
+
+// publish publishes an event about a service, recording the notification once
+// it is delivered.
+func (m *TrainMonitor) publish(e events.Event, journey *config.TrainConfig, svc *rtt.Service, notificationType, value string) error {
+	return m.publisher.Publish(e, &events.Notified{
+		Date:       m.runDate(svc),
+		Journey:    journey.Name,
+		ServiceUID: svc.ServiceUid,
+		Type:       notificationType,
+		Value:      value,
+	})
+}
+
+// eventService identifies a service in the events published about it, with
+// the booked departure of the journey's leg from from.
+func (m *TrainMonitor) eventService(journey *config.TrainConfig, svc *rtt.Service, from, to string) events.Service {
+	var departure string
+	for _, leg := range journey.Route() {
+		if leg.From == from {
+			departure = leg.Departure
+		}
+	}
+	return events.Service{
+		Journey:    journey.Name,
+		ServiceUID: svc.ServiceUid,
+		RunDate:    m.runDate(svc),
+		Departure:  departure,
+		Operator:   svc.AtocName,
+		From:       from,
+		To:         to,
+	}
+}
+
+// runDate returns the date a service runs on, in history.DateFormat.
+func (m *TrainMonitor) runDate(svc *rtt.Service) string {
+	if svc.RunDate != "" {
+		return svc.RunDate
+	}
+	return m.clock.Now().Format(history.DateFormat)
+}
+
+// RestoreNotificationState restores the last known Northern Line status from
+// today's journey history.
+func (m *TubeMonitor) RestoreNotificationState() {
+	status, ok := m.history.LastTubeStatus(m.clock.Now().Format(history.DateFormat))
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	m.lastStatus = status.Status
+	m.lastStatusReason = status.Reason
+	m.mu.Unlock()
+
+	m.logger.WithField("status", status.Status).Debug("restored northern line status from history")
+}
+
+// publish publishes a Northern Line event, recording the notification once it
+// is delivered.
+func (m *TubeMonitor) publish(e events.Event, notificationType, status string) error {
+	return m.publisher.Publish(e, &events.Notified{
+		Date:  m.clock.Now().Format(history.DateFormat),
+		Type:  notificationType,
+		Value: status,
+	})
+}
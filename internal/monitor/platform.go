@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// CheckPlatform looks up the service for the leg and notifies when its
+// platform is first confirmed or changes.
+func (m *TrainMonitor) CheckPlatform(ctx context.Context, journey *config.TrainConfig, leg config.Leg) error {
+	depTime, err := m.parseTimeToday(leg.Departure)
+	if err != nil {
+		return fmt.Errorf("parsing departure time: %w", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"from":      leg.From,
+		"to":        leg.To,
+		"departure": leg.Departure,
+	}).Debug("checking train platform")
+
+	resp, err := m.rttClient.Search(ctx, leg.From, leg.To, depTime)
+	if err != nil {
+		return fmt.Errorf("searching for train: %w", err)
+	}
+	if resp == nil || len(resp.Services) == 0 {
+		return nil
+	}
+
+	service := m.findMatchingService(resp.Services, leg.Departure)
+	if service == nil {
+		return nil
+	}
+
+	m.observeSearch(journey, leg, service)
+	if isCancelled(&service.LocationDetail) {
+		return nil
+	}
+
+	return m.handlePlatform(service, journey, leg)
+}
+
+// handlePlatform notifies the first time a service's platform is confirmed and
+// again whenever it changes from the last platform notified.
+func (m *TrainMonitor) handlePlatform(svc *rtt.Service, journey *config.TrainConfig, leg config.Leg) error {
+	detail := &svc.LocationDetail
+	platform := detail.Platform
+	if platform == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	lastPlatform, notified := m.notifiedPlatforms[svc.ServiceUid]
+	confirmed := !notified && detail.PlatformConfirmed
+	changed := notified && platform != lastPlatform
+	if confirmed || changed {
+		m.notifiedPlatforms[svc.ServiceUid] = platform
+	}
+	m.mu.Unlock()
+
+	switch {
+	case confirmed:
+		m.logger.WithFields(logrus.Fields{
+			"service":  svc.ServiceUid,
+			"platform": platform,
+		}).Info("platform confirmed")
+		err := m.publish(events.PlatformConfirmed{
+			Service:       m.eventService(journey, svc, leg.From, leg.To),
+			DepartureTime: detail.GbttBookedDeparture,
+			Platform:      platform,
+		}, journey, svc, notifyPlatform, platform)
+		if err != nil {
+			return err
+		}
+
+	case changed:
+		m.logger.WithFields(logrus.Fields{
+			"service":       svc.ServiceUid,
+			"platform":      platform,
+			"last_platform": lastPlatform,
+		}).Warn("platform changed")
+		err := m.publish(events.PlatformChanged{
+			Service:          m.eventService(journey, svc, leg.From, leg.To),
+			DepartureTime:    detail.GbttBookedDeparture,
+			PreviousPlatform: lastPlatform,
+			Platform:         platform,
+		}, journey, svc, notifyPlatform, platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,28 +12,45 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/danpilch/trainpal/internal/api/rtt"
-	"github.com/danpilch/trainpal/internal/notify"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
 )
 
 type TrainMonitor struct {
-	rttClient *rtt.Client
-	notifier  *notify.Notifier
+	rttClient rtt.API
+	publisher Publisher
+	history   *history.Store
+	clock     clock.Clock
 	logger    *logrus.Logger
 
 	mu                 sync.Mutex
 	notifiedDelays     map[string]int
 	notifiedCancels    map[string]bool
 	notifiedDepartures map[string]bool
+	notifiedPlatforms  map[string]string
+	// notifiedArrivalDelays holds the highest arrival delay threshold notified
+	notifiedArrivalDelays map[string]int
+	// notifiedConnections is keyed by "<feeder uid>-><connecting uid>"
+	notifiedConnections map[string]int
+
+	delayRepayClaims []DelayRepayClaim
 }
 
-func NewTrainMonitor(rttClient *rtt.Client, notifier *notify.Notifier, logger *logrus.Logger) *TrainMonitor {
+func NewTrainMonitor(rttClient rtt.API, publisher Publisher, store *history.Store, clk clock.Clock, logger *logrus.Logger) *TrainMonitor {
 	return &TrainMonitor{
-		rttClient:          rttClient,
-		notifier:           notifier,
-		logger:             logger,
-		notifiedDelays:     make(map[string]int),
-		notifiedCancels:    make(map[string]bool),
-		notifiedDepartures: make(map[string]bool),
+		rttClient:             rttClient,
+		publisher:             publisher,
+		history:               store,
+		clock:                 clk,
+		logger:                logger,
+		notifiedDelays:        make(map[string]int),
+		notifiedCancels:       make(map[string]bool),
+		notifiedDepartures:    make(map[string]bool),
+		notifiedPlatforms:     make(map[string]string),
+		notifiedArrivalDelays: make(map[string]int),
+		notifiedConnections:   make(map[string]int),
 	}
 }
 
@@ -42,30 +60,49 @@ func (m *TrainMonitor) ResetNotificationState() {
 	m.notifiedDelays = make(map[string]int)
 	m.notifiedCancels = make(map[string]bool)
 	m.notifiedDepartures = make(map[string]bool)
+	m.notifiedPlatforms = make(map[string]string)
+	m.notifiedArrivalDelays = make(map[string]int)
+	m.notifiedConnections = make(map[string]int)
 }
 
 // GetExpectedArrivalTime returns the expected arrival time at the destination for a given train.
 func (m *TrainMonitor) GetExpectedArrivalTime(ctx context.Context, from, to, departureTime string) (time.Time, error) {
-	depTime, err := parseTimeToday(departureTime)
+	arrival, _, err := m.expectedArrival(ctx, from, to, departureTime)
+	return arrival, err
+}
+
+// expectedArrival finds the service departing from at departureTime and returns its
+// expected arrival time at to, along with the matched service.
+func (m *TrainMonitor) expectedArrival(ctx context.Context, from, to, departureTime string) (time.Time, *rtt.Service, error) {
+	depTime, err := m.parseTimeToday(departureTime)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("parsing departure time: %w", err)
+		return time.Time{}, nil, fmt.Errorf("parsing departure time: %w", err)
 	}
 
 	resp, err := m.rttClient.Search(ctx, from, to, depTime)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("searching for train: %w", err)
+		return time.Time{}, nil, fmt.Errorf("searching for train: %w", err)
 	}
 
 	if resp == nil || len(resp.Services) == 0 {
-		return time.Time{}, fmt.Errorf("no services found")
+		return time.Time{}, nil, fmt.Errorf("no services found")
 	}
 
 	service := m.findMatchingService(resp.Services, departureTime)
 	if service == nil {
-		return time.Time{}, fmt.Errorf("no matching service found")
+		return time.Time{}, nil, fmt.Errorf("no matching service found")
 	}
 
-	details, err := m.rttClient.GetService(ctx, service.ServiceUid, depTime)
+	arrival, err := m.serviceArrival(ctx, service, to, depTime)
+	if err != nil {
+		return time.Time{}, service, err
+	}
+	return arrival, service, nil
+}
+
+// serviceArrival returns the expected (or booked) arrival time of a service at a station.
+func (m *TrainMonitor) serviceArrival(ctx context.Context, service *rtt.Service, to string, runDate time.Time) (time.Time, error) {
+	details, err := m.rttClient.GetService(ctx, service.ServiceUid, runDate)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("getting service details: %w", err)
 	}
@@ -87,9 +124,7 @@ func (m *TrainMonitor) GetExpectedArrivalTime(ctx context.Context, from, to, dep
 				return time.Time{}, fmt.Errorf("parsing arrival time: %w", err)
 			}
 
-			now := time.Now()
-			return time.Date(now.Year(), now.Month(), now.Day(),
-				arrivalTime.Hour(), arrivalTime.Minute(), 0, 0, time.Local), nil
+			return onDay(runDate, arrivalTime), nil
 		}
 	}
 
@@ -102,8 +137,9 @@ func (m *TrainMonitor) GetExpectedArrivalTime(ctx context.Context, from, to, dep
 	return time.Time{}, fmt.Errorf("destination %s not found in service", to)
 }
 
-func (m *TrainMonitor) CheckDelay(ctx context.Context, from, to, departureTime string) error {
-	depTime, err := parseTimeToday(departureTime)
+func (m *TrainMonitor) CheckDelay(ctx context.Context, journey *config.TrainConfig, leg config.Leg) error {
+	from, to, departureTime := leg.From, leg.To, leg.Departure
+	depTime, err := m.parseTimeToday(departureTime)
 	if err != nil {
 		return fmt.Errorf("parsing departure time: %w", err)
 	}
@@ -134,14 +170,36 @@ func (m *TrainMonitor) CheckDelay(ctx context.Context, from, to, departureTime s
 		return nil
 	}
 
-	return m.processService(service, from, to, false)
+	return m.processService(ctx, service, journey, leg, false)
 }
 
 // CheckStatus checks train status and always sends a notification (on time or delayed).
-func (m *TrainMonitor) CheckStatus(ctx context.Context, from, to, departureTime string) error {
-	depTime, err := parseTimeToday(departureTime)
+func (m *TrainMonitor) CheckStatus(ctx context.Context, journey *config.TrainConfig, leg config.Leg) error {
+	service, err := m.findLegService(ctx, leg)
+	if err != nil || service == nil {
+		return err
+	}
+	return m.processService(ctx, service, journey, leg, true)
+}
+
+// CurrentStatus returns the status of the leg's service as the event a status
+// check would publish, without publishing or recording anything. The event is
+// nil if the service was not found.
+func (m *TrainMonitor) CurrentStatus(ctx context.Context, journey *config.TrainConfig, leg config.Leg) (events.Event, error) {
+	service, err := m.findLegService(ctx, leg)
+	if err != nil || service == nil {
+		return nil, err
+	}
+	return m.statusEvent(ctx, service, journey, leg), nil
+}
+
+// findLegService searches for the service booked for the leg, returning nil
+// if there is none.
+func (m *TrainMonitor) findLegService(ctx context.Context, leg config.Leg) (*rtt.Service, error) {
+	from, to, departureTime := leg.From, leg.To, leg.Departure
+	depTime, err := m.parseTimeToday(departureTime)
 	if err != nil {
-		return fmt.Errorf("parsing departure time: %w", err)
+		return nil, fmt.Errorf("parsing departure time: %w", err)
 	}
 
 	m.logger.WithFields(logrus.Fields{
@@ -152,7 +210,7 @@ func (m *TrainMonitor) CheckStatus(ctx context.Context, from, to, departureTime
 
 	resp, err := m.rttClient.Search(ctx, from, to, depTime)
 	if err != nil {
-		return fmt.Errorf("searching for train: %w", err)
+		return nil, fmt.Errorf("searching for train: %w", err)
 	}
 
 	if resp == nil || len(resp.Services) == 0 {
@@ -161,16 +219,57 @@ func (m *TrainMonitor) CheckStatus(ctx context.Context, from, to, departureTime
 			"to":        to,
 			"departure": departureTime,
 		}).Warn("no services found")
-		return nil
+		return nil, nil
 	}
 
 	service := m.findMatchingService(resp.Services, departureTime)
 	if service == nil {
 		m.logger.WithField("departure", departureTime).Warn("no matching service found for departure time")
-		return nil
+		return nil, nil
 	}
+	return service, nil
+}
 
-	return m.processService(service, from, to, true)
+// statusEvent describes a service as running on time, delayed or cancelled.
+func (m *TrainMonitor) statusEvent(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg) events.Event {
+	detail := &svc.LocationDetail
+	service := m.eventService(journey, svc, leg.From, leg.To)
+	if isCancelled(detail) {
+		reason := detail.CancelReasonShortText
+		if reason == "" {
+			reason = "No reason provided"
+		}
+		return events.TrainCancelled{
+			Service:      service,
+			Reason:       reason,
+			Alternatives: m.alternatives(ctx, svc, journey, leg),
+		}
+	}
+
+	platform := detail.Platform
+	if platform == "" {
+		platform = "TBC"
+	}
+	var delayMins int
+	if detail.RealtimeDeparture != "" && detail.GbttBookedDeparture != "" {
+		delayMins = m.calculateDelay(detail.GbttBookedDeparture, detail.RealtimeDeparture)
+	}
+	if delayMins > 0 {
+		return events.TrainDelayed{
+			Service:      service,
+			DelayMinutes: delayMins,
+			ExpectedTime: detail.RealtimeDeparture,
+			Platform:     platform,
+			Arrival:      m.expectedArrivalAt(ctx, svc, leg.To),
+			Alternatives: m.delayAlternatives(ctx, svc, journey, leg, delayMins),
+		}
+	}
+	return events.TrainOnTime{
+		Service:       service,
+		DepartureTime: detail.GbttBookedDeparture,
+		Platform:      platform,
+		Arrival:       m.expectedArrivalAt(ctx, svc, leg.To),
+	}
 }
 
 func (m *TrainMonitor) findMatchingService(services []rtt.Service, targetTime string) *rtt.Service {
@@ -184,11 +283,20 @@ func (m *TrainMonitor) findMatchingService(services []rtt.Service, targetTime st
 	return nil
 }
 
-func (m *TrainMonitor) processService(svc *rtt.Service, from, to string, alwaysNotify bool) error {
+func (m *TrainMonitor) processService(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg, alwaysNotify bool) error {
 	detail := &svc.LocationDetail
 
-	if detail.DisplayAs == "CANCELLED_CALL" || detail.DisplayAs == "CANCELLED" {
-		return m.handleCancellation(svc, from, to)
+	m.observeSearch(journey, leg, svc)
+
+	if isCancelled(detail) {
+		return m.handleCancellation(ctx, svc, journey, leg)
+	}
+
+	if err := m.handlePlatform(svc, journey, leg); err != nil {
+		m.logger.WithFields(logrus.Fields{
+			"service": svc.ServiceUid,
+			"error":   err,
+		}).Error("failed to send platform notification")
 	}
 
 	platform := detail.Platform
@@ -210,10 +318,10 @@ func (m *TrainMonitor) processService(svc *rtt.Service, from, to string, alwaysN
 				"expected":      detail.RealtimeDeparture,
 				"platform":      platform,
 			}).Warn("train delayed")
-			return m.notifier.SendTrainDelay(svc.ServiceUid, from, to, delayMins, detail.RealtimeDeparture, platform)
+			return m.publish(m.statusEvent(ctx, svc, journey, leg), journey, svc, notifyStatus, strconv.Itoa(delayMins))
 		}
 		// Delay check: use deduplication
-		return m.handleDelay(svc, from, to, delayMins)
+		return m.handleDelay(ctx, svc, journey, leg, delayMins)
 	}
 
 	m.logger.WithFields(logrus.Fields{
@@ -223,13 +331,13 @@ func (m *TrainMonitor) processService(svc *rtt.Service, from, to string, alwaysN
 	}).Info("train running on time")
 
 	if alwaysNotify {
-		return m.notifier.SendTrainOnTime(svc.ServiceUid, from, to, detail.GbttBookedDeparture, platform)
+		return m.publish(m.statusEvent(ctx, svc, journey, leg), journey, svc, notifyStatus, "0")
 	}
 
 	return nil
 }
 
-func (m *TrainMonitor) handleCancellation(svc *rtt.Service, from, to string) error {
+func (m *TrainMonitor) handleCancellation(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg) error {
 	m.mu.Lock()
 	alreadyNotified := m.notifiedCancels[svc.ServiceUid]
 	if !alreadyNotified {
@@ -251,10 +359,19 @@ func (m *TrainMonitor) handleCancellation(svc *rtt.Service, from, to string) err
 		"reason":  reason,
 	}).Warn("train cancelled")
 
-	return m.notifier.SendTrainCancellation(svc.ServiceUid, from, to, reason)
+	alts := m.alternatives(ctx, svc, journey, leg)
+	err := m.publish(events.TrainCancelled{
+		Service:      m.eventService(journey, svc, leg.From, leg.To),
+		Reason:       reason,
+		Alternatives: alts,
+	}, journey, svc, notifyCancellation, reason)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
-func (m *TrainMonitor) handleDelay(svc *rtt.Service, from, to string, delayMins int) error {
+func (m *TrainMonitor) handleDelay(ctx context.Context, svc *rtt.Service, journey *config.TrainConfig, leg config.Leg, delayMins int) error {
 	delayBucket := delayMins / 5 * 5
 
 	m.mu.Lock()
@@ -287,13 +404,18 @@ func (m *TrainMonitor) handleDelay(svc *rtt.Service, from, to string, delayMins
 		"platform":      platform,
 	}).Warn("train delayed")
 
-	return m.notifier.SendTrainDelay(
-		svc.ServiceUid,
-		from, to,
-		delayMins,
-		detail.RealtimeDeparture,
-		platform,
-	)
+	err := m.publish(events.TrainDelayed{
+		Service:      m.eventService(journey, svc, leg.From, leg.To),
+		DelayMinutes: delayMins,
+		ExpectedTime: detail.RealtimeDeparture,
+		Platform:     platform,
+		Arrival:      m.expectedArrivalAt(ctx, svc, leg.To),
+		Alternatives: m.delayAlternatives(ctx, svc, journey, leg, delayMins),
+	}, journey, svc, notifyDelay, strconv.Itoa(delayBucket))
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
 func (m *TrainMonitor) calculateDelay(scheduled, actual string) int {
@@ -313,8 +435,9 @@ func (m *TrainMonitor) calculateDelay(scheduled, actual string) int {
 	return int(diff.Minutes())
 }
 
-func (m *TrainMonitor) CheckDeparture(ctx context.Context, from, to, departureTime string) (departed bool, err error) {
-	depTime, err := parseTimeToday(departureTime)
+func (m *TrainMonitor) CheckDeparture(ctx context.Context, journey *config.TrainConfig, leg config.Leg) (departed bool, err error) {
+	from, to, departureTime := leg.From, leg.To, leg.Departure
+	depTime, err := m.parseTimeToday(departureTime)
 	if err != nil {
 		return false, fmt.Errorf("parsing departure time: %w", err)
 	}
@@ -347,6 +470,17 @@ func (m *TrainMonitor) CheckDeparture(ctx context.Context, from, to, departureTi
 	for _, loc := range details.Locations {
 		if loc.CRS == from {
 			if loc.RealtimeDepartureActual {
+				m.observe(service, events.Observation{
+					Journey:         journey.Name,
+					From:            from,
+					To:              to,
+					BookedDeparture: loc.GbttBookedDeparture,
+					ActualDeparture: loc.RealtimeDeparture,
+					Departed:        true,
+					DelayMinutes:    m.calculateDelay(loc.GbttBookedDeparture, loc.RealtimeDeparture),
+					Platform:        loc.Platform,
+				})
+
 				m.mu.Lock()
 				alreadyNotified := m.notifiedDepartures[service.ServiceUid]
 				if !alreadyNotified {
@@ -375,7 +509,12 @@ func (m *TrainMonitor) CheckDeparture(ctx context.Context, from, to, departureTi
 					"platform":       platform,
 				}).Info("train departed")
 
-				if err := m.notifier.SendTrainDeparture(service.ServiceUid, from, to, departureTimeStr, platform); err != nil {
+				err := m.publish(events.TrainDeparted{
+					Service:       m.eventService(journey, service, from, to),
+					DepartureTime: departureTimeStr,
+					Platform:      platform,
+				}, journey, service, notifyDeparture, departureTimeStr)
+				if err != nil {
 					return true, fmt.Errorf("sending departure notification: %w", err)
 				}
 				return true, nil
@@ -387,8 +526,9 @@ func (m *TrainMonitor) CheckDeparture(ctx context.Context, from, to, departureTi
 	return false, nil
 }
 
-func (m *TrainMonitor) CheckArrival(ctx context.Context, from, to, departureTime string) (arrived bool, err error) {
-	depTime, err := parseTimeToday(departureTime)
+func (m *TrainMonitor) CheckArrival(ctx context.Context, journey *config.TrainConfig, leg config.Leg) (arrived bool, err error) {
+	from, to, departureTime := leg.From, leg.To, leg.Departure
+	depTime, err := m.parseTimeToday(departureTime)
 	if err != nil {
 		return false, fmt.Errorf("parsing departure time: %w", err)
 	}
@@ -399,16 +539,12 @@ func (m *TrainMonitor) CheckArrival(ctx context.Context, from, to, departureTime
 		"departure": departureTime,
 	}).Info("checking train arrival")
 
-	resp, err := m.rttClient.Search(ctx, from, to, depTime)
+	// Follow the traveller onto a later train if they missed a connection
+	// or their train was cancelled, so the arrival is end to end.
+	service, booked, err := m.takenService(ctx, journey, leg)
 	if err != nil {
-		return false, fmt.Errorf("searching for train: %w", err)
-	}
-
-	if resp == nil || len(resp.Services) == 0 {
-		return false, nil
+		return false, fmt.Errorf("finding service: %w", err)
 	}
-
-	service := m.findMatchingService(resp.Services, departureTime)
 	if service == nil {
 		return false, nil
 	}
@@ -426,16 +562,54 @@ func (m *TrainMonitor) CheckArrival(ctx context.Context, from, to, departureTime
 					arrivalTime = loc.GbttBookedArrival
 				}
 
+				var errs []error
+				bookedArrival := loc.GbttBookedArrival
+				if booked == nil {
+					bookedArrival = ""
+				} else if booked.ServiceUid != service.ServiceUid {
+					estimate, _, err := m.arrivalEstimate(ctx, booked, to, depTime)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("getting booked arrival: %w", err))
+						bookedArrival = ""
+					} else {
+						bookedArrival = estimate.Booked
+					}
+				}
+
+				obs := events.Observation{
+					Journey:       journey.Name,
+					From:          from,
+					To:            to,
+					BookedArrival: loc.GbttBookedArrival,
+					ActualArrival: arrivalTime,
+					Arrived:       true,
+					ArrivalDelay:  m.calculateDelay(loc.GbttBookedArrival, loc.RealtimeArrival),
+				}
+				if bookedArrival != "" && loc.RealtimeArrival != "" {
+					obs.JourneyDelay = m.calculateDelay(bookedArrival, loc.RealtimeArrival)
+				}
+				m.observe(service, obs)
+
 				m.logger.WithFields(logrus.Fields{
 					"service":      service.ServiceUid,
 					"station":      to,
 					"arrival_time": arrivalTime,
 				}).Info("train arrived")
 
-				if err := m.notifier.SendTrainArrival(service.ServiceUid, to, arrivalTime); err != nil {
-					return true, fmt.Errorf("sending arrival notification: %w", err)
+				err := m.publish(events.TrainArrived{
+					Service:     m.eventService(journey, service, from, to),
+					ArrivalTime: arrivalTime,
+				}, journey, service, notifyArrival, arrivalTime)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("sending arrival notification: %w", err))
 				}
-				return true, nil
+
+				if loc.RealtimeArrival != "" {
+					if err := m.recordDelayRepay(journey, service, bookedArrival, loc.RealtimeArrival); err != nil {
+						errs = append(errs, fmt.Errorf("sending delay repay notification: %w", err))
+					}
+				}
+				return true, errors.Join(errs...)
 			}
 			break
 		}
@@ -444,12 +618,21 @@ func (m *TrainMonitor) CheckArrival(ctx context.Context, from, to, departureTime
 	return false, nil
 }
 
-func parseTimeToday(timeStr string) (time.Time, error) {
+func isCancelled(detail *rtt.LocationDetail) bool {
+	return detail.DisplayAs == "CANCELLED_CALL" || detail.DisplayAs == "CANCELLED"
+}
+
+// onDay returns the clock time of t on the same date as day.
+func onDay(day, t time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+}
+
+func (m *TrainMonitor) parseTimeToday(timeStr string) (time.Time, error) {
 	t, err := time.Parse("1504", timeStr)
 	if err != nil {
 		return time.Time{}, err
 	}
-	now := time.Now()
+	now := m.clock.Now()
 	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
 }
 
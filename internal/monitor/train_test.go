@@ -0,0 +1,229 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/api/rtt/rtttest"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// recordingPublisher keeps the events published since it was last drained,
+// leaving out history records.
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(e events.Event, _ *events.Notified) error {
+	if _, ok := e.(events.Record); !ok {
+		p.events = append(p.events, e)
+	}
+	return nil
+}
+
+func (p *recordingPublisher) drain() []events.Event {
+	published := p.events
+	p.events = nil
+	return published
+}
+
+func TestTrainMonitorFollowsService(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.Local)
+	at := func(hour, min int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	}
+
+	srv := rtttest.NewServer()
+	defer srv.Close()
+	srv.Add(rtttest.Service{
+		UID: "W12345", Operator: "South Western Railway", From: "WIN", To: "WAT",
+		Departure: at(8, 0), Arrival: at(9, 0),
+		Timeline: []rtttest.Update{
+			rtttest.Delayed(at(7, 20), 10),
+			rtttest.Departed(at(8, 10), 10),
+			rtttest.Arrived(at(9, 20), 20),
+		},
+	})
+
+	sim := clock.NewFake(day)
+	srv.SetNow(sim.Now)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	publisher := &recordingPublisher{}
+	m := NewTrainMonitor(rtt.NewClient(srv.URL, "", ""), publisher, nil, sim, logger)
+
+	journey := &config.TrainConfig{Name: "morning", From: "WIN", To: "WAT", Departure: "0800"}
+	leg := journey.Route()[0]
+	service := events.Service{
+		Journey:    "morning",
+		ServiceUID: "W12345",
+		RunDate:    "2025-01-06",
+		Departure:  "0800",
+		Operator:   "South Western Railway",
+		From:       "WIN",
+		To:         "WAT",
+	}
+	ctx := context.Background()
+
+	steps := []struct {
+		name  string
+		at    time.Time
+		check func() (done bool, err error)
+		done  bool
+		want  []events.Event
+	}{
+		{
+			name:  "status on time",
+			at:    at(7, 0),
+			check: func() (bool, error) { return false, m.CheckStatus(ctx, journey, leg) },
+			want: []events.Event{events.TrainOnTime{
+				Service:       service,
+				DepartureTime: "0800",
+				Platform:      "TBC",
+				Arrival:       &events.ArrivalEstimate{Station: "WAT", Booked: "0900", Expected: "0900"},
+			}},
+		},
+		{
+			name:  "delay check on time",
+			at:    at(7, 0),
+			check: func() (bool, error) { return false, m.CheckDelay(ctx, journey, leg) },
+		},
+		{
+			name:  "delay check delayed",
+			at:    at(7, 30),
+			check: func() (bool, error) { return false, m.CheckDelay(ctx, journey, leg) },
+			want: []events.Event{events.TrainDelayed{
+				Service:      service,
+				DelayMinutes: 10,
+				ExpectedTime: "0810",
+				Platform:     "TBC",
+				Arrival:      &events.ArrivalEstimate{Station: "WAT", Booked: "0900", Expected: "0910", DelayMinutes: 10},
+			}},
+		},
+		{
+			name:  "delay check delay already notified",
+			at:    at(7, 45),
+			check: func() (bool, error) { return false, m.CheckDelay(ctx, journey, leg) },
+		},
+		{
+			name:  "departure check not departed",
+			at:    at(8, 5),
+			check: func() (bool, error) { return m.CheckDeparture(ctx, journey, leg) },
+		},
+		{
+			name:  "departure check departed",
+			at:    at(8, 10),
+			check: func() (bool, error) { return m.CheckDeparture(ctx, journey, leg) },
+			done:  true,
+			want: []events.Event{events.TrainDeparted{
+				Service:       service,
+				DepartureTime: "0810",
+				Platform:      "TBC",
+			}},
+		},
+		{
+			name:  "departure check departure already notified",
+			at:    at(8, 12),
+			check: func() (bool, error) { return m.CheckDeparture(ctx, journey, leg) },
+			done:  true,
+		},
+		{
+			name:  "arrival check not arrived",
+			at:    at(9, 10),
+			check: func() (bool, error) { return m.CheckArrival(ctx, journey, leg) },
+		},
+		{
+			name:  "arrival check arrived",
+			at:    at(9, 20),
+			check: func() (bool, error) { return m.CheckArrival(ctx, journey, leg) },
+			done:  true,
+			want: []events.Event{
+				events.TrainArrived{Service: service, ArrivalTime: "0920"},
+				events.DelayRepayEligible{
+					Service:         service,
+					BookedDeparture: "0800",
+					BookedArrival:   "0900",
+					ActualArrival:   "0920",
+					DelayMinutes:    20,
+					Band:            15,
+				},
+			},
+		},
+	}
+	for _, step := range steps {
+		sim.Set(step.at)
+		done, err := step.check()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if done != step.done {
+			t.Errorf("%s: done = %t, want %t", step.name, done, step.done)
+		}
+		if got := publisher.drain(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: published %s, want %s", step.name, formatEvents(got), formatEvents(step.want))
+		}
+	}
+
+	want := []DelayRepayClaim{{
+		Journey:         "morning",
+		ServiceUID:      "W12345",
+		RunDate:         "2025-01-06",
+		From:            "WIN",
+		To:              "WAT",
+		BookedDeparture: "0800",
+		BookedArrival:   "0900",
+		ActualArrival:   "0920",
+		DelayMinutes:    20,
+		Band:            15,
+	}}
+	if got := m.DelayRepayClaims(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DelayRepayClaims() = %+v, want %+v", got, want)
+	}
+}
+
+// formatEvents formats events as JSON, so pointer fields are shown by value.
+func formatEvents(es []events.Event) string {
+	b, err := json.Marshal(es)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func TestTrainMonitorRestoresDelayRepayClaims(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), logger)
+	if err != nil {
+		t.Fatalf("history.Open() error = %v", err)
+	}
+	defer store.Close()
+
+	claim := DelayRepayClaim{
+		Journey: "morning", ServiceUID: "W12345", RunDate: "2025-01-06",
+		From: "WIN", To: "WAT", BookedDeparture: "0800",
+		BookedArrival: "0900", ActualArrival: "0935", DelayMinutes: 35, Band: 30,
+	}
+	if err := store.Handle(events.DelayRepayClaimed{Date: claim.RunDate, Claim: claim}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	sim := clock.NewFake(time.Date(2025, 1, 7, 6, 0, 0, 0, time.Local))
+	m := NewTrainMonitor(nil, &recordingPublisher{}, store, sim, logger)
+	m.RestoreNotificationState()
+
+	if got, want := m.DelayRepayClaims(), []DelayRepayClaim{claim}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DelayRepayClaims() = %+v, want %+v", got, want)
+	}
+}
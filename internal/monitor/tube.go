@@ -7,12 +7,16 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/danpilch/trainpal/internal/api/tfl"
-	"github.com/danpilch/trainpal/internal/notify"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
 )
 
 type TubeMonitor struct {
 	tflClient *tfl.Client
-	notifier  *notify.Notifier
+	publisher Publisher
+	history   *history.Store
+	clock     clock.Clock
 	logger    *logrus.Logger
 
 	mu               sync.Mutex
@@ -20,10 +24,12 @@ type TubeMonitor struct {
 	lastStatusReason string
 }
 
-func NewTubeMonitor(tflClient *tfl.Client, notifier *notify.Notifier, logger *logrus.Logger) *TubeMonitor {
+func NewTubeMonitor(tflClient *tfl.Client, publisher Publisher, store *history.Store, clk clock.Clock, logger *logrus.Logger) *TubeMonitor {
 	return &TubeMonitor{
 		tflClient: tflClient,
-		notifier:  notifier,
+		publisher: publisher,
+		history:   store,
+		clock:     clk,
 		logger:    logger,
 	}
 }
@@ -63,6 +69,11 @@ func (m *TubeMonitor) CheckStatus(ctx context.Context) error {
 		"reason":   reason,
 	}).Info("northern line status")
 
+	observed := events.TubeStatusObserved{Time: m.clock.Now(), Status: statusDesc, Reason: reason}
+	if err := m.publisher.Publish(observed, nil); err != nil {
+		m.logger.WithField("error", err).Warn("failed to record northern line status history")
+	}
+
 	if !statusChanged {
 		return nil
 	}
@@ -79,12 +90,27 @@ func (m *TubeMonitor) CheckStatus(ctx context.Context) error {
 			"status": statusDesc,
 			"reason": reason,
 		}).Warn("northern line disruption detected")
-		return m.notifier.SendTubeDisruption(statusDesc, reason)
+		if err := m.publish(events.TubeStatusChanged{Status: statusDesc, Reason: reason}, notifyTube, statusDesc); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// CurrentStatus returns the Northern Line's status and reason without sending
+// any notification.
+func (m *TubeMonitor) CurrentStatus(ctx context.Context) (string, string, error) {
+	status, err := m.tflClient.GetNorthernLineStatus(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if len(status.LineStatuses) == 0 {
+		return "Unknown", "", nil
+	}
+	return status.LineStatuses[0].StatusSeverityDescription, status.LineStatuses[0].Reason, nil
+}
+
 func (m *TubeMonitor) SendStatusSummary(ctx context.Context) error {
 	status, err := m.tflClient.GetNorthernLineStatus(ctx)
 	if err != nil {
@@ -92,7 +118,7 @@ func (m *TubeMonitor) SendStatusSummary(ctx context.Context) error {
 	}
 
 	if len(status.LineStatuses) == 0 {
-		return m.notifier.SendTubeStatus("Unknown", "Unable to retrieve status")
+		return m.publisher.Publish(events.TubeStatusSummary{Status: "Unknown", Reason: "Unable to retrieve status"}, nil)
 	}
 
 	currentStatus := status.LineStatuses[0]
@@ -104,5 +130,5 @@ func (m *TubeMonitor) SendStatusSummary(ctx context.Context) error {
 		"reason": reason,
 	}).Info("sending northern line status summary")
 
-	return m.notifier.SendTubeStatus(statusDesc, reason)
+	return m.publish(events.TubeStatusSummary{Status: statusDesc, Reason: reason}, notifyTubeSummary, statusDesc)
 }
@@ -0,0 +1,246 @@
+// Package notifiers builds the notify backends, recipients and templates
+// described by the config.
+package notifiers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/history"
+	"github.com/danpilch/trainpal/internal/notify"
+)
+
+// Options are the runtime settings shared by every backend.
+type Options struct {
+	Mutes   string            // runtime mutes file, empty for none
+	State   *notify.StateFile // backend state kept across restarts
+	History *history.Store    // where held back alerts are recorded
+	Clock   clock.Clock
+	Logger  *logrus.Logger
+}
+
+// NewSender builds the sender for a single configured backend of a recipient,
+// taking any credentials not set in the config from the environment.
+// Recipient is empty for the top-level notifiers.
+func NewSender(recipient string, cfg config.NotifierConfig, opts Options) (notify.Sender, error) {
+	logger := opts.Logger
+	key := notify.NotifierKey(recipient, cfg.DisplayName())
+	switch cfg.Type {
+	case config.NotifierPushover:
+		token := valueOrEnv(cfg.Token, "PUSHOVER_TOKEN")
+		user := valueOrEnv(cfg.User, "PUSHOVER_USER")
+		if token == "" || user == "" {
+			return nil, fmt.Errorf("pushover: token and user are required (or PUSHOVER_TOKEN and PUSHOVER_USER)")
+		}
+		p, err := notify.NewPushover(token, user, emergency(cfg.Emergency), key, opts.State, opts.Clock, logger)
+		if err != nil {
+			return nil, fmt.Errorf("pushover: %w", err)
+		}
+		return p, nil
+
+	case config.NotifierNtfy:
+		return notify.NewNtfy(cfg.URL, cfg.Topic, valueOrEnv(cfg.Token, "NTFY_TOKEN"), logger), nil
+
+	case config.NotifierSlack:
+		url := valueOrEnv(cfg.URL, "SLACK_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("slack: url is required (or SLACK_WEBHOOK_URL)")
+		}
+		return notify.NewSlack(url, logger), nil
+
+	case config.NotifierDiscord:
+		url := valueOrEnv(cfg.URL, "DISCORD_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("discord: url is required (or DISCORD_WEBHOOK_URL)")
+		}
+		return notify.NewDiscord(url, logger), nil
+
+	case config.NotifierEmail:
+		host := valueOrEnv(cfg.Host, "SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("email: host is required (or SMTP_HOST)")
+		}
+		port := cfg.Port
+		if port == 0 && os.Getenv("SMTP_PORT") != "" {
+			p, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+			if err != nil {
+				return nil, fmt.Errorf("email: invalid SMTP_PORT: %w", err)
+			}
+			port = p
+		}
+		email := notify.NewEmail(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), cfg.From, cfg.To, cfg.Plaintext, logger)
+		if cfg.Digest == "" {
+			return email, nil
+		}
+		at, err := cfg.DigestTime()
+		if err != nil {
+			return nil, fmt.Errorf("email: %w", err)
+		}
+		digest, err := notify.NewEmailDigest(email, at, key, opts.State, opts.Clock, logger)
+		if err != nil {
+			return nil, fmt.Errorf("email: %w", err)
+		}
+		return digest, nil
+
+	case config.NotifierTelegram:
+		token := valueOrEnv(cfg.Token, "TELEGRAM_BOT_TOKEN")
+		chatID := valueOrEnv(cfg.ChatID, "TELEGRAM_CHAT_ID")
+		if token == "" || chatID == "" {
+			return nil, fmt.Errorf("telegram: token and chat_id are required (or TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID)")
+		}
+		return notify.NewTelegram(cfg.URL, token, chatID, logger), nil
+
+	case config.NotifierWebhook:
+		secret := valueOrEnv(cfg.Secret, "WEBHOOK_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("webhook: secret is required (or WEBHOOK_SECRET)")
+		}
+		return notify.NewWebhook(cfg.URL, secret, logger), nil
+
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+// NewSenders builds a sender that delivers to every configured backend of a
+// recipient, each subject to its quiet hours and the runtime mutes in the
+// mutes file. Recipient is empty for the top-level notifiers.
+func NewSenders(recipient string, cfgs []config.NotifierConfig, opts Options) (notify.Sender, error) {
+	var senders notify.Multi
+	for i, cfg := range cfgs {
+		sender, err := NewSender(recipient, cfg, opts)
+		if err != nil {
+			return nil, fmt.Errorf("notifiers[%d]: %w", i, err)
+		}
+		senders = append(senders, notify.NewQuiet(recipient, cfg.DisplayName(), sender, quietHours(cfg.QuietHours), opts.Mutes, opts.History, opts.Clock, opts.Logger))
+	}
+	if len(senders) == 1 {
+		return senders[0], nil
+	}
+	return senders, nil
+}
+
+// NewRecipients builds the configured recipients. The top-level notifiers are
+// a recipient receiving every alert.
+func NewRecipients(cfg *config.Config, opts Options) (notify.Recipients, error) {
+	var recipients notify.Recipients
+	if len(cfg.Notifiers) > 0 {
+		sender, err := NewSenders("", cfg.Notifiers, opts)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, notify.NewRecipient("", nil, true, "", sender))
+	}
+
+	for _, rc := range cfg.Recipients {
+		sender, err := NewSenders(rc.Name, rc.Notifiers, opts)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", rc.Name, err)
+		}
+		tube := false
+		for _, j := range cfg.Journeys {
+			if j.Tube != "" && rc.Subscribes(j.Name) {
+				tube = true
+			}
+		}
+		recipients = append(recipients, notify.NewRecipient(rc.Name, rc.Journeys, tube, rc.MinSeverity, sender))
+
+		opts.Logger.WithFields(logrus.Fields{
+			"recipient":    rc.Name,
+			"journeys":     rc.Journeys,
+			"min_severity": rc.MinSeverity,
+			"notifiers":    len(rc.Notifiers),
+		}).Debug("configured recipient")
+	}
+	return recipients, nil
+}
+
+// Chat is a Telegram notifier, whose chat can also send commands to the bot.
+type Chat struct {
+	Recipient string   // empty for a top-level notifier
+	Journeys  []string // the recipient's journeys, empty for every journey
+	Notifier  string   // the notifier's key in the mutes file
+	Token     string   // the bot token, shared by chats using the same bot
+	Telegram  *notify.Telegram
+}
+
+// Chats returns the configured Telegram notifiers.
+func Chats(cfg *config.Config, opts Options) ([]Chat, error) {
+	var chats []Chat
+	add := func(recipient string, journeys []string, cfgs []config.NotifierConfig) error {
+		for _, nc := range cfgs {
+			if nc.Type != config.NotifierTelegram {
+				continue
+			}
+			sender, err := NewSender(recipient, nc, opts)
+			if err != nil {
+				return err
+			}
+			chats = append(chats, Chat{
+				Recipient: recipient,
+				Journeys:  journeys,
+				Notifier:  notify.NotifierKey(recipient, nc.DisplayName()),
+				Token:     valueOrEnv(nc.Token, "TELEGRAM_BOT_TOKEN"),
+				Telegram:  sender.(*notify.Telegram),
+			})
+		}
+		return nil
+	}
+	if err := add("", nil, cfg.Notifiers); err != nil {
+		return nil, err
+	}
+	for _, rc := range cfg.Recipients {
+		if err := add(rc.Name, rc.Journeys, rc.Notifiers); err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", rc.Name, err)
+		}
+	}
+	return chats, nil
+}
+
+// NewTemplates parses the configured templates.
+func NewTemplates(cfgs map[string]config.TemplateConfig) (notify.Templates, error) {
+	templates := make(notify.Templates, len(cfgs))
+	for kind, cfg := range cfgs {
+		title, body, err := cfg.Parse(kind)
+		if err != nil {
+			return nil, fmt.Errorf("templates.%s: %w", kind, err)
+		}
+		templates[kind] = notify.Template{Title: title, Body: body}
+	}
+	return templates, nil
+}
+
+func emergency(cfg *config.EmergencyConfig) *notify.Emergency {
+	if cfg == nil {
+		return nil
+	}
+	return &notify.Emergency{
+		Kinds:          cfg.Kinds,
+		Journeys:       cfg.Journeys,
+		Retry:          cfg.RetryInterval(),
+		Expire:         cfg.ExpireAfter(),
+		EscalateTo:     cfg.Escalate.User,
+		EscalateBefore: time.Duration(cfg.Escalate.Before) * time.Minute,
+	}
+}
+
+func quietHours(cfgs []config.QuietHours) []notify.QuietHours {
+	windows := make([]notify.QuietHours, len(cfgs))
+	for i, q := range cfgs {
+		windows[i] = notify.QuietHours{Window: q.Window, Defer: q.Defer}
+	}
+	return windows
+}
+
+func valueOrEnv(value, envVar string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(envVar)
+}
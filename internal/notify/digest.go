@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+)
+
+// EmailDigest collects the day's messages from every monitor and emails them
+// as a single digest at a set time each day. The collected messages are kept
+// in the state file, so a restart does not lose them.
+type EmailDigest struct {
+	email  *Email
+	at     time.Duration
+	key    string
+	state  *StateFile
+	clock  clock.Clock
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	entries []digestEntry
+	timer   clock.Timer
+	closed  bool
+}
+
+// digestEntry is a collected message, without the event it was formatted
+// from.
+type digestEntry struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	Priority int       `json:"priority,omitempty"`
+	URL      string    `json:"url,omitempty"`
+}
+
+func (e digestEntry) severity() string {
+	return Message{Kind: e.Kind, Priority: e.Priority}.Severity()
+}
+
+// NewEmailDigest creates a digest sent through email at the given time of
+// day, as an offset from midnight. Collected messages are saved in state under
+// key. A digest that was missed while trainpal was stopped is sent straight
+// away.
+func NewEmailDigest(email *Email, at time.Duration, key string, state *StateFile, clk clock.Clock, logger *logrus.Logger) (*EmailDigest, error) {
+	d := &EmailDigest{
+		email:  email,
+		at:     at,
+		key:    key,
+		state:  state,
+		clock:  clk,
+		logger: logger,
+	}
+	if err := state.Load(d.stateKey(), &d.entries); err != nil {
+		return nil, err
+	}
+
+	now := clk.Now()
+	next := nextDigest(now, d.at)
+	if len(d.entries) > 0 && d.entries[0].Time.Before(next.AddDate(0, 0, -1)) {
+		logger.WithField("messages", len(d.entries)).Info("sending missed email digest")
+		next = now
+	}
+	d.mu.Lock()
+	d.schedule(now, next)
+	d.mu.Unlock()
+	return d, nil
+}
+
+// Send adds the message to the next digest.
+func (d *EmailDigest) Send(m Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, digestEntry{
+		Time:     d.clock.Now(),
+		Kind:     m.Kind,
+		Title:    m.Title,
+		Body:     m.Body,
+		Priority: m.Priority,
+		URL:      m.URL,
+	})
+	if err := d.state.Save(d.stateKey(), d.entries); err != nil {
+		d.entries = d.entries[:len(d.entries)-1]
+		return err
+	}
+	return nil
+}
+
+// Close stops the digest timer. Collected messages stay in the state file for
+// the next run.
+func (d *EmailDigest) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	return nil
+}
+
+func (d *EmailDigest) stateKey() string {
+	return "digest:" + d.key
+}
+
+// schedule sets the timer for the digest at next. The caller must hold d.mu.
+func (d *EmailDigest) schedule(now, next time.Time) {
+	if d.closed {
+		return
+	}
+	d.logger.WithField("next_digest", next.Format(time.RFC3339)).Debug("scheduled email digest")
+	d.timer = d.clock.AfterFunc(next.Sub(now), func() {
+		if err := d.Flush(); err != nil {
+			d.logger.WithField("error", err).Error("failed to send email digest")
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		now := d.clock.Now()
+		d.schedule(now, nextDigest(now, d.at))
+	})
+}
+
+func nextDigest(now time.Time, at time.Duration) time.Time {
+	hour, minute := int(at/time.Hour), int(at%time.Hour/time.Minute)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return next
+}
+
+// Flush emails the collected messages now. Nothing is sent if there are none,
+// and they are kept for the next digest if sending fails.
+func (d *EmailDigest) Flush() error {
+	d.mu.Lock()
+	entries := d.entries
+	d.mu.Unlock()
+
+	if len(entries) == 0 {
+		d.logger.Debug("no messages for email digest")
+		return nil
+	}
+
+	subject := fmt.Sprintf("trainpal digest for %s", entries[0].Time.Format("Mon 2 Jan"))
+	text, html, err := renderDigest(subject, entries)
+	if err != nil {
+		return fmt.Errorf("rendering email digest: %w", err)
+	}
+	data, err := buildEmail(d.email.from, d.email.to, subject, d.clock.Now(), text, html)
+	if err != nil {
+		return fmt.Errorf("building email digest: %w", err)
+	}
+	if err := d.email.deliver(data); err != nil {
+		return fmt.Errorf("sending email digest: %w", err)
+	}
+
+	d.mu.Lock()
+	// keep anything collected while the digest was being sent
+	d.entries = append([]digestEntry(nil), d.entries[len(entries):]...)
+	err = d.state.Save(d.stateKey(), d.entries)
+	d.mu.Unlock()
+	if err != nil {
+		d.logger.WithField("error", err).Warn("failed to save email digest state")
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"messages": len(entries),
+		"to":       strings.Join(d.email.to, ","),
+	}).Info("email digest sent")
+
+	return nil
+}
+
+// digestItem is a digest entry prepared for the templates.
+type digestItem struct {
+	Time   string
+	Title  string
+	Lines  []string
+	URL    string
+	Border htmltemplate.CSS
+}
+
+var digestText = template.Must(template.New("digest").Parse(`{{.Subject}}
+{{range .Items}}
+{{.Time}}  {{.Title}}
+{{range .Lines}}    {{.}}
+{{end}}{{if .URL}}    {{.URL}}
+{{end}}{{end}}`))
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Subject}}</h2>
+<table cellpadding="6" style="border-collapse: collapse">
+{{range .Items}}<tr>
+<td style="{{.Border}}; vertical-align: top; white-space: nowrap">{{.Time}}</td>
+<td><strong>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</strong>{{range .Lines}}<br>{{.}}{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func renderDigest(subject string, entries []digestEntry) (string, string, error) {
+	data := struct {
+		Subject string
+		Items   []digestItem
+	}{Subject: subject}
+	for _, e := range entries {
+		data.Items = append(data.Items, digestItem{
+			Time:   e.Time.Format("15:04"),
+			Title:  e.Title,
+			Lines:  strings.Split(e.Body, "\n"),
+			URL:    e.URL,
+			Border: htmltemplate.CSS("border-left: 4px solid " + severityColors[e.severity()]),
+		})
+	}
+
+	var text, html strings.Builder
+	if err := digestText.Execute(&text, data); err != nil {
+		return "", "", err
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
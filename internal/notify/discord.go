@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// discordColors are the embed colours for each severity.
+var discordColors = map[string]int{
+	SeverityInfo:    0x2eb886,
+	SeverityWarning: 0xdaa038,
+	SeverityDanger:  0xa30200,
+}
+
+// Discord delivers messages to a Discord channel webhook.
+type Discord struct {
+	httpClient *http.Client
+	webhookURL string
+	logger     *logrus.Logger
+}
+
+func NewDiscord(webhookURL string, logger *logrus.Logger) *Discord {
+	return &Discord{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhookURL: webhookURL,
+		logger:     logger,
+	}
+}
+
+type discordPayload struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// discordMessage builds the webhook payload for a message.
+func discordMessage(m Message) discordPayload {
+	embed := discordEmbed{
+		Title:       m.Title,
+		URL:         m.URL,
+		Description: m.Body,
+		Color:       discordColors[m.Severity()],
+	}
+	for _, f := range m.Fields {
+		// Discord rejects embeds with empty field values.
+		value := f.Value
+		if value == "" {
+			value = "-"
+		}
+		embed.Fields = append(embed.Fields, discordField{Name: f.Name, Value: value, Inline: true})
+	}
+	return discordPayload{Embeds: []discordEmbed{embed}}
+}
+
+func (d *Discord) Send(m Message) error {
+	status, err := postJSON(d.httpClient, d.webhookURL, discordMessage(m))
+	if err != nil {
+		return fmt.Errorf("sending discord notification: %w", err)
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"title":  m.Title,
+		"status": status,
+	}).Debug("notification sent")
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDiscordGolden(t *testing.T) {
+	testGolden(t, "discord", func(url string, logger *logrus.Logger) Sender {
+		return NewDiscord(url, logger)
+	})
+}
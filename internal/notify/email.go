@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSMTPPort is the mail submission port, used with STARTTLS.
+const DefaultSMTPPort = 587
+
+// Email delivers messages over SMTP, upgrading the connection with STARTTLS.
+// Servers that don't offer STARTTLS are refused unless plaintext is allowed.
+type Email struct {
+	host      string
+	addr      string
+	username  string
+	password  string
+	from      string
+	to        []string
+	plaintext bool
+	logger    *logrus.Logger
+}
+
+// NewEmail creates an email sender. Without a username the server is used
+// unauthenticated. With plaintext set, mail is sent unencrypted to servers
+// that don't offer STARTTLS, such as a local relay.
+func NewEmail(host string, port int, username, password, from string, to []string, plaintext bool, logger *logrus.Logger) *Email {
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	return &Email{
+		host:      host,
+		addr:      net.JoinHostPort(host, strconv.Itoa(port)),
+		username:  username,
+		password:  password,
+		from:      from,
+		to:        to,
+		plaintext: plaintext,
+		logger:    logger,
+	}
+}
+
+func (e *Email) Send(m Message) error {
+	body := m.Body
+	if m.URL != "" {
+		body += "\n\n" + m.URL
+	}
+	data, err := buildEmail(e.from, e.to, m.Title, time.Now(), body, "")
+	if err != nil {
+		return fmt.Errorf("building email notification: %w", err)
+	}
+	if err := e.deliver(data); err != nil {
+		return fmt.Errorf("sending email notification: %w", err)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"title": m.Title,
+		"to":    strings.Join(e.to, ","),
+	}).Debug("notification sent")
+
+	return nil
+}
+
+// deliver sends a complete email to every recipient.
+func (e *Email) deliver(data []byte) error {
+	conn, err := net.DialTimeout("tcp", e.addr, 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", e.addr, err)
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to %s: %w", e.addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	} else if !e.plaintext {
+		return fmt.Errorf("%s does not offer STARTTLS; set plaintext to send without it", e.addr)
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := c.Mail(e.from); err != nil {
+		return fmt.Errorf("setting sender: %w", err)
+	}
+	for _, rcpt := range e.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("adding recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("starting message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("finishing message: %w", err)
+	}
+	return c.Quit()
+}
+
+// buildEmail formats an email with a plain-text body, and an HTML alternative
+// if html is set.
+func buildEmail(from string, to []string, subject string, date time.Time, text, html string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// Format turns an event into the message sent for it. It reports false for
+// events that are not notified.
+func Format(e events.Event) (Message, bool) {
+	switch e := e.(type) {
+	case events.TrainDelayed:
+		body := fmt.Sprintf("Train %s from %s to %s is delayed by %d minutes.\nExpected: %s, Platform: %s",
+			e.ServiceUID, e.From, e.To, e.DelayMinutes, e.ExpectedTime, e.Platform)
+		body += formatArrival(e.Arrival)
+		body += formatAlternatives(e.Alternatives)
+		return Message{
+			Kind:     KindTrainDelay,
+			Title:    "Train Delay Alert",
+			Body:     body,
+			Priority: PriorityHigh,
+			Fields: append([]Field{
+				{Name: "Platform", Value: e.Platform},
+				{Name: "Expected", Value: e.ExpectedTime},
+				{Name: "Delay", Value: minutes(e.DelayMinutes)},
+			}, arrivalFields(e.Arrival)...),
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.TrainOnTime:
+		body := fmt.Sprintf("Train %s from %s to %s is running on time.\nDeparture: %s, Platform: %s",
+			e.ServiceUID, e.From, e.To, e.DepartureTime, e.Platform)
+		body += formatArrival(e.Arrival)
+		return Message{
+			Kind:     KindTrainOnTime,
+			Title:    "Train Status",
+			Body:     body,
+			Priority: PriorityNormal,
+			Fields: append([]Field{
+				{Name: "Platform", Value: e.Platform},
+				{Name: "Expected", Value: e.DepartureTime},
+			}, arrivalFields(e.Arrival)...),
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.ArrivalDelayed:
+		return Message{
+			Kind:  KindArrivalDelay,
+			Title: "Arrival Delay Alert",
+			Body: fmt.Sprintf("Train %s from %s to %s is now expected at %s at %s, %d minutes late (booked %s)",
+				e.ServiceUID, e.From, e.To, e.Arrival.Station, e.Arrival.Expected, e.Arrival.DelayMinutes, e.Arrival.Booked),
+			Priority: PriorityHigh,
+			Fields: []Field{
+				{Name: "Station", Value: e.Arrival.Station},
+				{Name: "Expected", Value: e.Arrival.Expected},
+				{Name: "Delay", Value: minutes(e.Arrival.DelayMinutes)},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.TrainArrived:
+		return Message{
+			Kind:     KindTrainArrival,
+			Title:    "Train Arrival",
+			Body:     fmt.Sprintf("Train %s has arrived at %s at %s", e.ServiceUID, e.To, e.ArrivalTime),
+			Priority: PriorityNormal,
+			Fields:   []Field{{Name: "Arrived", Value: e.ArrivalTime}},
+			URL:      serviceURL(e.Service),
+			Data:     e,
+		}, true
+
+	case events.DelayRepayEligible:
+		return Message{
+			Kind:  KindDelayRepay,
+			Title: "Delay Repay",
+			Body: fmt.Sprintf("Eligible for %d-minute Delay Repay: arrived %d minutes late.\n"+
+				"Service: %s on %s\nFrom %s to %s, booked departure %s\nBooked arrival: %s, Actual arrival: %s",
+				e.Band, e.DelayMinutes, e.ServiceUID, e.RunDate, e.From, e.To, e.BookedDeparture, e.BookedArrival, e.ActualArrival),
+			Priority: PriorityNormal,
+			Fields: []Field{
+				{Name: "Band", Value: minutes(e.Band)},
+				{Name: "Delay", Value: minutes(e.DelayMinutes)},
+				{Name: "Arrived", Value: e.ActualArrival},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.TrainDeparted:
+		return Message{
+			Kind:  KindTrainDeparture,
+			Title: "Train Departed",
+			Body: fmt.Sprintf("Train %s from %s to %s has departed at %s from Platform %s",
+				e.ServiceUID, e.From, e.To, e.DepartureTime, e.Platform),
+			Priority: PriorityNormal,
+			Fields: []Field{
+				{Name: "Platform", Value: e.Platform},
+				{Name: "Departed", Value: e.DepartureTime},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.PlatformConfirmed:
+		return Message{
+			Kind:  KindPlatformConfirmed,
+			Title: "Platform Confirmed",
+			Body: fmt.Sprintf("Train %s from %s to %s departing %s will leave from Platform %s",
+				e.ServiceUID, e.From, e.To, e.DepartureTime, e.Platform),
+			Priority: PriorityNormal,
+			Fields: []Field{
+				{Name: "Platform", Value: e.Platform},
+				{Name: "Departure", Value: e.DepartureTime},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.PlatformChanged:
+		return Message{
+			Kind:  KindPlatformChanged,
+			Title: "Platform Change",
+			Body: fmt.Sprintf("Train %s from %s to %s departing %s has moved from Platform %s to Platform %s",
+				e.ServiceUID, e.From, e.To, e.DepartureTime, e.PreviousPlatform, e.Platform),
+			Priority: PriorityHigh,
+			Fields: []Field{
+				{Name: "Platform", Value: e.Platform},
+				{Name: "Previously", Value: e.PreviousPlatform},
+				{Name: "Departure", Value: e.DepartureTime},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.TrainCancelled:
+		body := fmt.Sprintf("Train %s from %s to %s has been CANCELLED.\nReason: %s",
+			e.ServiceUID, e.From, e.To, e.Reason)
+		body += formatAlternatives(e.Alternatives)
+		return Message{
+			Kind:     KindTrainCancellation,
+			Title:    "Train Cancellation Alert",
+			Body:     body,
+			Priority: PriorityHigh,
+			Fields:   []Field{{Name: "Reason", Value: e.Reason}},
+			URL:      serviceURL(e.Service),
+			Data:     e,
+		}, true
+
+	case events.TubeStatusChanged:
+		return Message{
+			Kind:     KindTubeDisruption,
+			Title:    "Tube Disruption Alert",
+			Body:     fmt.Sprintf("Northern Line: %s\n%s", e.Status, e.Reason),
+			Priority: PriorityHigh,
+			Data:     e,
+		}, true
+
+	case events.TubeStatusSummary:
+		body := e.Status
+		if e.Reason != "" {
+			body = fmt.Sprintf("%s\n%s", e.Status, e.Reason)
+		}
+		return Message{
+			Kind:     KindTubeStatus,
+			Title:    "Northern Line Status",
+			Body:     body,
+			Priority: PriorityNormal,
+			Data:     e,
+		}, true
+
+	case events.ConnectionAtRisk:
+		body := fmt.Sprintf("Connection at %s is at risk: arriving %s, connecting train departs %s (%d minutes to change).",
+			e.From, e.ArrivalTime, e.DepartureTime, e.SlackMinutes)
+		if e.Next != nil {
+			body += "\nNext viable connection: " + formatAlternative(*e.Next)
+		}
+		return Message{
+			Kind:     KindConnectionAtRisk,
+			Title:    "Connection At Risk",
+			Body:     body,
+			Priority: PriorityHigh,
+			Fields: []Field{
+				{Name: "Arrival", Value: e.ArrivalTime},
+				{Name: "Departure", Value: e.DepartureTime},
+				{Name: "Change time", Value: minutes(e.SlackMinutes)},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+
+	case events.ConnectionMissed:
+		body := fmt.Sprintf("Connection at %s will be missed: arriving %s, connecting train departs %s.",
+			e.From, e.ArrivalTime, e.DepartureTime)
+		arrival := e.ArrivalTime
+		if e.FeederCancelled {
+			body = fmt.Sprintf("Connection at %s will be missed: train %s to it is cancelled, connecting train departs %s.",
+				e.From, e.FeederUID, e.DepartureTime)
+			arrival = "Cancelled"
+		}
+		if e.Next != nil {
+			body += "\nNext viable connection: " + formatAlternative(*e.Next)
+		} else {
+			body += "\nNo later connection found."
+		}
+		return Message{
+			Kind:     KindConnectionMissed,
+			Title:    "Connection Missed",
+			Body:     body,
+			Priority: PriorityHigh,
+			Fields: []Field{
+				{Name: "Arrival", Value: arrival},
+				{Name: "Departure", Value: e.DepartureTime},
+			},
+			URL:  serviceURL(e.Service),
+			Data: e,
+		}, true
+	}
+	return Message{}, false
+}
+
+// serviceURL links to an event's service, assuming it runs today if the run
+// date is unknown.
+func serviceURL(svc events.Service) string {
+	if svc.ServiceUID == "" {
+		return ""
+	}
+	runDate := svc.RunDate
+	if runDate == "" {
+		runDate = time.Now().Format("2006-01-02")
+	}
+	return ServiceURL(svc.ServiceUID, runDate)
+}
+
+func formatArrival(arrival *events.ArrivalEstimate) string {
+	if arrival == nil {
+		return ""
+	}
+	if arrival.DelayMinutes > 0 {
+		return fmt.Sprintf("\nArriving %s at %s (%d minutes late)", arrival.Station, arrival.Expected, arrival.DelayMinutes)
+	}
+	return fmt.Sprintf("\nArriving %s at %s (on time)", arrival.Station, arrival.Expected)
+}
+
+func arrivalFields(arrival *events.ArrivalEstimate) []Field {
+	if arrival == nil {
+		return nil
+	}
+	return []Field{{Name: "Arrival", Value: fmt.Sprintf("%s at %s", arrival.Expected, arrival.Station)}}
+}
+
+func formatAlternatives(alternatives []events.Alternative) string {
+	if len(alternatives) == 0 {
+		return ""
+	}
+	s := "\nAlternatives:"
+	for _, a := range alternatives {
+		s += "\n- " + formatAlternative(a)
+	}
+	return s
+}
+
+func formatAlternative(a events.Alternative) string {
+	s := fmt.Sprintf("%s departing %s", a.ServiceUID, a.Departure)
+	if a.Platform != "" {
+		s += fmt.Sprintf(" from Platform %s", a.Platform)
+	}
+	if a.Arrival != "" {
+		s += fmt.Sprintf(", arriving %s", a.Arrival)
+	}
+	return s
+}
+
+func minutes(m int) string {
+	return fmt.Sprintf("%d min", m)
+}
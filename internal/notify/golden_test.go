@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenKinds are the kinds of event whose payloads are compared against
+// golden files.
+var goldenKinds = []string{
+	events.KindTrainDelayed,
+	events.KindTrainOnTime,
+	events.KindArrivalDelayed,
+	events.KindTrainArrived,
+	events.KindDelayRepayEligible,
+	events.KindTrainDeparted,
+	events.KindPlatformConfirmed,
+	events.KindPlatformChanged,
+	events.KindTrainCancelled,
+	events.KindTubeStatusChanged,
+	events.KindTubeStatusSummary,
+	events.KindConnectionAtRisk,
+	events.KindConnectionMissed,
+}
+
+// testGolden sends the example event of every kind through a sender posting
+// to a test server, and compares each JSON payload it posts against
+// testdata/<name>_<kind>.golden. Run with -update to rewrite the files.
+func testGolden(t *testing.T, name string, newSender func(url string, logger *logrus.Logger) Sender) {
+	t.Helper()
+	for _, kind := range goldenKinds {
+		t.Run(kind, func(t *testing.T) {
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			e, ok := events.Example(kind)
+			if !ok {
+				t.Fatalf("no example %s event", kind)
+			}
+			msg, ok := Format(e)
+			if !ok {
+				t.Fatalf("no message for %s event", kind)
+			}
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			if err := newSender(server.URL, logger).Send(msg); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			var got bytes.Buffer
+			if err := json.Indent(&got, body, "", "  "); err != nil {
+				t.Fatalf("payload is not JSON: %v\n%s", err, body)
+			}
+			got.WriteByte('\n')
+
+			path := filepath.Join("testdata", name+"_"+kind+".golden")
+			if *update {
+				if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading golden file: %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("payload does not match %s:\n got: %s\nwant: %s", path, got.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Mutes are the runtime mutes set with the mute command. They are kept in a
+// file so the command can change them while trainpal is running.
+type Mutes struct {
+	All        time.Time            `json:"all,omitzero"`
+	Recipients map[string]time.Time `json:"recipients,omitempty"`
+	Notifiers  map[string]time.Time `json:"notifiers,omitempty"`
+}
+
+// LoadMutes reads the mutes file. A missing file has no mutes.
+func LoadMutes(path string) (Mutes, error) {
+	var m Mutes
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("reading mutes file: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parsing mutes file: %w", err)
+	}
+	return m, nil
+}
+
+// SaveMutes replaces the mutes file, dropping mutes that have expired.
+func SaveMutes(path string, m Mutes) error {
+	now := time.Now()
+	if !m.All.After(now) {
+		m.All = time.Time{}
+	}
+	for _, mutes := range []map[string]time.Time{m.Recipients, m.Notifiers} {
+		for name, until := range mutes {
+			if !until.After(now) {
+				delete(mutes, name)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding mutes: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mutes-*")
+	if err != nil {
+		return fmt.Errorf("writing mutes file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing mutes file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing mutes file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing mutes file: %w", err)
+	}
+	return nil
+}
+
+// MuteNotifier mutes one notifier, named as in the mutes file, until the given
+// time. A zero time unmutes it.
+func MuteNotifier(path, notifier string, until time.Time) error {
+	mutes, err := LoadMutes(path)
+	if err != nil {
+		return err
+	}
+	if mutes.Notifiers == nil {
+		mutes.Notifiers = make(map[string]time.Time)
+	}
+	mutes.Notifiers[notifier] = until
+	return SaveMutes(path, mutes)
+}
+
+// MutedUntil returns when the mute on a recipient's notifier ends, if it is
+// muted at now. Recipient is empty for the top-level notifiers.
+func (m Mutes) MutedUntil(recipient, notifier string, now time.Time) (time.Time, bool) {
+	until := m.All
+	if r := m.Recipients[recipient]; recipient != "" && r.After(until) {
+		until = r
+	}
+	if n := m.Notifiers[NotifierKey(recipient, notifier)]; n.After(until) {
+		until = n
+	}
+	return until, until.After(now)
+}
+
+// NotifierKey names a notifier in the mutes file: "alice/phone" for a
+// recipient's notifier, or just the name for a top-level one.
+func NotifierKey(recipient, notifier string) string {
+	if recipient == "" {
+		return notifier
+	}
+	return recipient + "/" + notifier
+}
@@ -0,0 +1,332 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+const (
+	PriorityNormal    = 0
+	PriorityHigh      = 1
+	PriorityEmergency = 2 // repeated until acknowledged, where the backend supports it
+)
+
+// Kinds of message, so backends can style each event differently.
+const (
+	KindGeneral           = "general"
+	KindTrainDelay        = events.KindTrainDelayed
+	KindTrainOnTime       = events.KindTrainOnTime
+	KindArrivalDelay      = events.KindArrivalDelayed
+	KindTrainArrival      = events.KindTrainArrived
+	KindDelayRepay        = events.KindDelayRepayEligible
+	KindTrainDeparture    = events.KindTrainDeparted
+	KindPlatformConfirmed = events.KindPlatformConfirmed
+	KindPlatformChanged   = events.KindPlatformChanged
+	KindTrainCancellation = events.KindTrainCancelled
+	KindTubeDisruption    = events.KindTubeStatusChanged
+	KindTubeStatus        = events.KindTubeStatusSummary
+	KindConnectionAtRisk  = events.KindConnectionAtRisk
+	KindConnectionMissed  = events.KindConnectionMissed
+)
+
+// Message is a formatted notification ready for delivery. Fields and URL
+// carry the key details and a link for backends that can display them; Body
+// always holds the full text. Data is the event the message was formatted
+// from, for structured backends such as webhooks. ID is the same on every
+// attempt to deliver a queued notification, so receivers can drop repeats; it
+// is empty for messages sent directly.
+type Message struct {
+	ID       string
+	Kind     string
+	Title    string
+	Body     string
+	Priority int
+	Fields   []Field
+	URL      string
+	Data     events.Event
+}
+
+// Field is a labelled detail of a message, such as the platform or delay.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Severities of a message, used by backends that colour messages and to
+// filter what recipients receive.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityDanger  = "danger"
+)
+
+// severityRank orders the severities, least severe first.
+var severityRank = map[string]int{
+	SeverityInfo:    0,
+	SeverityWarning: 1,
+	SeverityDanger:  2,
+}
+
+// severityColors are the colours used to show each severity.
+var severityColors = map[string]string{
+	SeverityInfo:    "#2eb886",
+	SeverityWarning: "#daa038",
+	SeverityDanger:  "#a30200",
+}
+
+// Severity classifies the message: cancellations, missed connections and
+// tube disruption are dangers, any other high priority message is a warning.
+func (m Message) Severity() string {
+	switch m.Kind {
+	case KindTrainCancellation, KindConnectionMissed, KindTubeDisruption:
+		return SeverityDanger
+	}
+	if m.Priority >= PriorityHigh {
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// ServiceURL links to a service's detailed page on realtimetrains.co.uk.
+func ServiceURL(serviceUID, runDate string) string {
+	return fmt.Sprintf("https://www.realtimetrains.co.uk/service/gb-nr:%s/%s/detailed", serviceUID, runDate)
+}
+
+// Sender delivers messages to a notification backend.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Router is a Sender fanning messages out to named backends, which can also
+// send to one backend at a time.
+type Router interface {
+	Sender
+	Route(m Message) []string
+	SendTo(backend string, m Message) error
+}
+
+// ErrUnknownBackend is returned when sending to a backend that is not
+// configured.
+var ErrUnknownBackend = errors.New("unknown backend")
+
+// SendError reports the backends that failed to deliver a message sent
+// through a Multi or Recipients, and those that delivered it, so callers can
+// retry only the failures.
+type SendError struct {
+	Sent   []string
+	Failed map[string]error
+}
+
+func (e *SendError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %v", name, e.Failed[name])
+	}
+	return fmt.Sprintf("%d of %d backends failed: %s", len(e.Failed), len(e.Failed)+len(e.Sent), strings.Join(msgs, "; "))
+}
+
+func (e *SendError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Partial reports whether some backends delivered the message.
+func (e *SendError) Partial() bool {
+	return len(e.Sent) > 0
+}
+
+// add records the result of sending through the named sender, merging the
+// results of a sender that fans out itself.
+func (e *SendError) add(name string, err error) {
+	var nested *SendError
+	switch {
+	case err == nil:
+		e.Sent = append(e.Sent, name)
+	case errors.As(err, &nested):
+		e.Sent = append(e.Sent, nested.Sent...)
+		for n, err := range nested.Failed {
+			e.Failed[n] = err
+		}
+	default:
+		e.Failed[name] = err
+	}
+}
+
+// err returns the SendError if any backend failed, otherwise nil.
+func (e *SendError) err() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// BackendName returns the name of a sender for logs and errors: its Name if
+// it has one, otherwise fallback.
+func BackendName(s Sender, fallback string) string {
+	if n, ok := s.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fallback
+}
+
+// Multi sends every message to all of its senders. If any fail it returns a
+// *SendError naming the failed backends.
+type Multi []Sender
+
+func (m Multi) Send(msg Message) error {
+	result := &SendError{Failed: make(map[string]error)}
+	for i, s := range m {
+		result.add(BackendName(s, strconv.Itoa(i)), s.Send(msg))
+	}
+	return result.err()
+}
+
+// Backends returns the individual backends behind a sender, unwrapping any
+// that wrap another.
+func Backends(s Sender) []Sender {
+	switch s := s.(type) {
+	case Multi:
+		var backends []Sender
+		for _, sender := range s {
+			backends = append(backends, Backends(sender)...)
+		}
+		return backends
+	case Recipients:
+		var backends []Sender
+		for _, r := range s {
+			backends = append(backends, Backends(r.sender)...)
+		}
+		return backends
+	case interface{ Unwrap() Sender }:
+		return Backends(s.Unwrap())
+	default:
+		return []Sender{s}
+	}
+}
+
+// Close closes every backend behind a sender that holds resources, such as
+// the timer of an email digest.
+func Close(s Sender) error {
+	var errs []error
+	for _, backend := range Backends(s) {
+		if c, ok := backend.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notifier formats trainpal's events as alerts and hands them to a Sender.
+type Notifier struct {
+	sender    Sender
+	templates Templates
+	logger    *logrus.Logger
+}
+
+// NewNotifier creates a notifier sending through sender, with the wording of
+// any events in templates overridden.
+func NewNotifier(sender Sender, templates Templates, logger *logrus.Logger) *Notifier {
+	return &Notifier{
+		sender:    sender,
+		templates: templates,
+		logger:    logger,
+	}
+}
+
+func (n *Notifier) Send(title, message string) error {
+	return n.SendWithPriority(title, message, PriorityNormal)
+}
+
+func (n *Notifier) SendWithPriority(title, message string, priority int) error {
+	return n.send(KindGeneral, title, message, priority)
+}
+
+func (n *Notifier) send(kind, title, body string, priority int) error {
+	return n.sender.Send(Message{
+		Kind:     kind,
+		Title:    title,
+		Body:     body,
+		Priority: priority,
+	})
+}
+
+// Handle formats an event and sends it, so a Notifier can subscribe to an
+// events.Bus.
+func (n *Notifier) Handle(e events.Event) error {
+	msg, ok := n.message(e)
+	if !ok {
+		return nil
+	}
+	return n.sender.Send(msg)
+}
+
+// Backends returns the names of the backends the event's notification goes
+// to, so it can be queued for each of them.
+func (n *Notifier) Backends(e events.Event) []string {
+	msg, ok := n.message(e)
+	if !ok {
+		return nil
+	}
+	if r, ok := n.sender.(Router); ok {
+		return r.Route(msg)
+	}
+	return []string{BackendName(n.sender, "default")}
+}
+
+// Deliver formats an event and sends it to the named backend as the
+// notification id. A backend that is no longer configured is skipped with a
+// warning.
+func (n *Notifier) Deliver(backend, id string, e events.Event) error {
+	msg, ok := n.message(e)
+	if !ok {
+		return nil
+	}
+	msg.ID = id
+	r, ok := n.sender.(Router)
+	if !ok {
+		return n.sender.Send(msg)
+	}
+	err := r.SendTo(backend, msg)
+	if errors.Is(err, ErrUnknownBackend) {
+		n.logger.WithFields(logrus.Fields{
+			"backend": backend,
+			"kind":    e.Kind(),
+		}).Warn("dropping notification for unconfigured backend")
+		return nil
+	}
+	return err
+}
+
+// message formats the notification for an event, if it has one.
+func (n *Notifier) message(e events.Event) (Message, bool) {
+	if _, ok := e.(events.Record); ok {
+		return Message{}, false
+	}
+	msg, ok := Format(e)
+	if !ok {
+		n.logger.WithField("kind", e.Kind()).Debug("no notification for event")
+		return Message{}, false
+	}
+	if err := n.templates.apply(e, &msg); err != nil {
+		n.logger.WithField("error", err).Warn("notification template failed, using default wording")
+	}
+	return msg, true
+}
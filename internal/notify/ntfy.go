@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultNtfyURL is the public ntfy server.
+const DefaultNtfyURL = "https://ntfy.sh"
+
+// ntfy message priorities.
+const (
+	ntfyPriorityDefault = "3"
+	ntfyPriorityHigh    = "4"
+)
+
+// ntfyTags are the emoji tags shown with each kind of message.
+var ntfyTags = map[string]string{
+	KindTrainDelay:        "hourglass_flowing_sand",
+	KindTrainOnTime:       "white_check_mark",
+	KindArrivalDelay:      "hourglass_flowing_sand",
+	KindTrainArrival:      "checkered_flag",
+	KindDelayRepay:        "moneybag",
+	KindTrainDeparture:    "train2",
+	KindPlatformConfirmed: "round_pushpin",
+	KindPlatformChanged:   "warning,round_pushpin",
+	KindTrainCancellation: "x,train2",
+	KindTubeDisruption:    "warning,metro",
+	KindTubeStatus:        "metro",
+	KindConnectionAtRisk:  "warning,repeat",
+	KindConnectionMissed:  "x,repeat",
+}
+
+// Ntfy delivers messages by publishing to an ntfy topic, on ntfy.sh or a
+// self-hosted server.
+type Ntfy struct {
+	httpClient *http.Client
+	url        string
+	token      string
+	logger     *logrus.Logger
+}
+
+// NewNtfy creates an ntfy sender publishing to topic on serverURL. The access
+// token is optional.
+func NewNtfy(serverURL, topic, token string, logger *logrus.Logger) *Ntfy {
+	if serverURL == "" {
+		serverURL = DefaultNtfyURL
+	}
+	return &Ntfy{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		url:        strings.TrimRight(serverURL, "/") + "/" + url.PathEscape(topic),
+		token:      token,
+		logger:     logger,
+	}
+}
+
+func (n *Ntfy) Send(m Message) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(m.Body))
+	if err != nil {
+		return fmt.Errorf("creating ntfy request: %w", err)
+	}
+	req.Header.Set("Title", m.Title)
+	req.Header.Set("Priority", ntfyPriority(m.Priority))
+	if tags := ntfyTags[m.Kind]; tags != "" {
+		req.Header.Set("Tags", tags)
+	}
+	if m.URL != "" {
+		req.Header.Set("Click", m.URL)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending ntfy notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sending ntfy notification: unexpected status code: %d", resp.StatusCode)
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"title":  m.Title,
+		"status": resp.StatusCode,
+	}).Debug("notification sent")
+
+	return nil
+}
+
+func ntfyPriority(priority int) string {
+	if priority >= PriorityHigh {
+		return ntfyPriorityHigh
+	}
+	return ntfyPriorityDefault
+}
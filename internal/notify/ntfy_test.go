@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNtfySend(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		token   string
+		message Message
+		status  int
+
+		wantPath    string
+		wantHeaders map[string]string
+		wantErr     bool
+	}{
+		{
+			name:  "high priority alert",
+			topic: "trains",
+			token: "tk_secret",
+			message: Message{
+				Kind:     KindTrainCancellation,
+				Title:    "Train Cancelled",
+				Body:     "The 0715 from WAT to SUR is cancelled.",
+				Priority: PriorityHigh,
+				URL:      "https://www.realtimetrains.co.uk/service/gb-nr:W12345/2025-01-06",
+			},
+			status:   http.StatusOK,
+			wantPath: "/trains",
+			wantHeaders: map[string]string{
+				"Title":         "Train Cancelled",
+				"Priority":      ntfyPriorityHigh,
+				"Tags":          "x,train2",
+				"Click":         "https://www.realtimetrains.co.uk/service/gb-nr:W12345/2025-01-06",
+				"Authorization": "Bearer tk_secret",
+			},
+		},
+		{
+			name:     "normal priority without token or link",
+			topic:    "trains",
+			message:  Message{Kind: KindTubeStatus, Title: "Northern Line", Body: "Good Service"},
+			status:   http.StatusOK,
+			wantPath: "/trains",
+			wantHeaders: map[string]string{
+				"Priority":      ntfyPriorityDefault,
+				"Tags":          "metro",
+				"Click":         "",
+				"Authorization": "",
+			},
+		},
+		{
+			name:     "topic is escaped",
+			topic:    "my trains/alerts",
+			message:  Message{Title: "Title", Body: "Body"},
+			status:   http.StatusOK,
+			wantPath: "/my%20trains%2Falerts",
+		},
+		{
+			name:     "any 2xx is delivered",
+			topic:    "trains",
+			message:  Message{Title: "Title", Body: "Body"},
+			status:   http.StatusAccepted,
+			wantPath: "/trains",
+		},
+		{
+			name:     "error status fails",
+			topic:    "trains",
+			message:  Message{Title: "Title", Body: "Body"},
+			status:   http.StatusTooManyRequests,
+			wantPath: "/trains",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				got, body = r, string(data)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			err := NewNtfy(server.URL, tt.topic, tt.token, logger).Send(tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got == nil {
+				t.Fatal("no request received")
+			}
+			if got.Method != http.MethodPost {
+				t.Errorf("method = %s, want POST", got.Method)
+			}
+			if got.URL.EscapedPath() != tt.wantPath {
+				t.Errorf("path = %s, want %s", got.URL.EscapedPath(), tt.wantPath)
+			}
+			if body != tt.message.Body {
+				t.Errorf("body = %q, want %q", body, tt.message.Body)
+			}
+			for name, want := range tt.wantHeaders {
+				if v := got.Header.Get(name); v != want {
+					t.Errorf("header %s = %q, want %q", name, v, want)
+				}
+			}
+		})
+	}
+}
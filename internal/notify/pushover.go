@@ -2,97 +2,310 @@ package notify
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/gregdel/pushover"
 	"github.com/sirupsen/logrus"
-)
 
-const (
-	PriorityNormal = 0
-	PriorityHigh   = 1
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
 )
 
-type Notifier struct {
+// Emergency selects the alerts Pushover sends at emergency priority, which
+// repeats them every Retry until they are acknowledged or Expire passes.
+// Unacknowledged alerts are passed on to EscalateTo, if set, EscalateBefore
+// the train's departure or when they expire.
+type Emergency struct {
+	Kinds          []string // default train_cancellation
+	Journeys       []string // default every journey
+	Retry          time.Duration
+	Expire         time.Duration
+	EscalateTo     string
+	EscalateBefore time.Duration
+}
+
+// Matches reports whether an alert of the given kind for the journey is an emergency.
+func (e Emergency) Matches(kind, journey string) bool {
+	kinds := e.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{events.KindTrainCancelled}
+	}
+	matched := false
+	for _, k := range kinds {
+		if k == kind {
+			matched = true
+		}
+	}
+	if !matched || len(e.Journeys) == 0 {
+		return matched
+	}
+	for _, j := range e.Journeys {
+		if j == journey {
+			return true
+		}
+	}
+	return false
+}
+
+// Pushover delivers messages through the Pushover API. Emergency alerts are
+// tracked until they are acknowledged, in the state file so tracking resumes
+// after a restart.
+type Pushover struct {
 	app       *pushover.Pushover
 	recipient *pushover.Recipient
+	emergency *Emergency
+	escalate  *pushover.Recipient
+	key       string
+	state     *StateFile
+	clock     clock.Clock
 	logger    *logrus.Logger
+
+	mu       sync.Mutex
+	tracking map[string]*trackedAlert // by receipt
+	timers   map[string]clock.Timer
+	closed   bool
+}
+
+// trackedAlert is an emergency alert waiting to be acknowledged.
+type trackedAlert struct {
+	Receipt  string    `json:"receipt"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	URL      string    `json:"url,omitempty"`
+	Deadline time.Time `json:"deadline"` // escalated if not acknowledged by then
 }
 
-func NewNotifier(token, userKey string, logger *logrus.Logger) *Notifier {
-	return &Notifier{
+// NewPushover creates a Pushover backend. Alerts matching emergency, if set,
+// are sent at emergency priority and escalated if not acknowledged in time.
+// Alerts still being tracked when trainpal stopped are saved in state under
+// key and tracked again.
+func NewPushover(token, userKey string, emergency *Emergency, key string, state *StateFile, clk clock.Clock, logger *logrus.Logger) (*Pushover, error) {
+	p := &Pushover{
 		app:       pushover.New(token),
 		recipient: pushover.NewRecipient(userKey),
+		emergency: emergency,
+		key:       key,
+		state:     state,
+		clock:     clk,
 		logger:    logger,
+		tracking:  make(map[string]*trackedAlert),
+		timers:    make(map[string]clock.Timer),
+	}
+	if emergency != nil && emergency.EscalateTo != "" {
+		p.escalate = pushover.NewRecipient(emergency.EscalateTo)
 	}
-}
 
-func (n *Notifier) Send(title, message string) error {
-	return n.SendWithPriority(title, message, PriorityNormal)
+	var saved []*trackedAlert
+	if err := state.Load(p.stateKey(), &saved); err != nil {
+		return nil, err
+	}
+	if p.emergency == nil {
+		saved = nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range saved {
+		p.track(t, 0)
+	}
+	if len(saved) > 0 {
+		logger.WithField("alerts", len(saved)).Info("resuming emergency notification tracking")
+	}
+	return p, nil
 }
 
-func (n *Notifier) SendWithPriority(title, message string, priority int) error {
-	msg := pushover.NewMessageWithTitle(message, title)
-	msg.Priority = priority
+func (p *Pushover) Send(m Message) error {
+	if p.isEmergency(m) {
+		m.Priority = PriorityEmergency
+	}
 
-	resp, err := n.app.SendMessage(msg, n.recipient)
+	resp, err := p.send(m, p.recipient)
 	if err != nil {
-		return fmt.Errorf("sending pushover notification: %w", err)
+		return err
 	}
 
-	n.logger.WithFields(logrus.Fields{
-		"title":      title,
+	p.logger.WithFields(logrus.Fields{
+		"title":      m.Title,
 		"status":     resp.Status,
 		"request_id": resp.ID,
+		"receipt":    resp.Receipt,
 	}).Debug("notification sent")
 
+	if m.Priority == PriorityEmergency && resp.Receipt != "" {
+		now := p.clock.Now()
+		t := &trackedAlert{
+			Receipt:  resp.Receipt,
+			Title:    m.Title,
+			Body:     m.Body,
+			URL:      m.URL,
+			Deadline: p.escalateAt(m, now),
+		}
+		p.mu.Lock()
+		// an alert already past its deadline is checked and escalated now
+		p.track(t, min(p.emergency.Retry, max(t.Deadline.Sub(now), 0)))
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// Close stops tracking emergency alerts. They stay in the state file to be
+// tracked again on the next run.
+func (p *Pushover) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for receipt, timer := range p.timers {
+		timer.Stop()
+		delete(p.timers, receipt)
+	}
 	return nil
 }
 
-func (n *Notifier) SendTrainDelay(trainID, from, to string, delayMinutes int, expectedTime, platform string) error {
-	title := "Train Delay Alert"
-	body := fmt.Sprintf("Train %s from %s to %s is delayed by %d minutes.\nExpected: %s, Platform: %s",
-		trainID, from, to, delayMinutes, expectedTime, platform)
-	return n.SendWithPriority(title, body, PriorityHigh)
+func (p *Pushover) stateKey() string {
+	return "pushover:" + p.key
+}
+
+func (p *Pushover) send(m Message, recipient *pushover.Recipient) (*pushover.Response, error) {
+	msg := pushover.NewMessageWithTitle(m.Body, m.Title)
+	msg.Priority = m.Priority
+	if m.URL != "" {
+		msg.URL = m.URL
+		msg.URLTitle = "Realtime Trains"
+	}
+	if m.Priority == PriorityEmergency {
+		msg.Priority = pushover.PriorityEmergency
+		msg.Retry = p.emergency.Retry
+		msg.Expire = p.emergency.Expire
+	}
+
+	resp, err := p.app.SendMessage(msg, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("sending pushover notification: %w", err)
+	}
+	return resp, nil
+}
+
+// isEmergency reports whether the message matches the emergency settings.
+func (p *Pushover) isEmergency(m Message) bool {
+	if p.emergency == nil {
+		return false
+	}
+	var journey string
+	if e, ok := m.Data.(events.ServiceEvent); ok {
+		journey = e.EventService().Journey
+	}
+	return p.emergency.Matches(m.Kind, journey)
 }
 
-func (n *Notifier) SendTrainOnTime(trainID, from, to, departureTime, platform string) error {
-	title := "Train Status"
-	body := fmt.Sprintf("Train %s from %s to %s is running on time.\nDeparture: %s, Platform: %s",
-		trainID, from, to, departureTime, platform)
-	return n.Send(title, body)
+// escalateAt returns when an emergency alert sent at sent should be escalated
+// if it is still unacknowledged: EscalateBefore the train's departure, or
+// when the alert expires if that is sooner or the departure is unknown.
+func (p *Pushover) escalateAt(m Message, sent time.Time) time.Time {
+	at := sent.Add(p.emergency.Expire)
+	e, ok := m.Data.(events.ServiceEvent)
+	if !ok {
+		return at
+	}
+	svc := e.EventService()
+	departure, err := time.ParseInLocation(history.DateFormat+" 1504", svc.RunDate+" "+svc.Departure, time.Local)
+	if err != nil {
+		return at
+	}
+	if deadline := departure.Add(-p.emergency.EscalateBefore); deadline.Before(at) {
+		return deadline
+	}
+	return at
 }
 
-func (n *Notifier) SendTrainArrival(trainID, station, arrivalTime string) error {
-	title := "Train Arrival"
-	body := fmt.Sprintf("Train %s has arrived at %s at %s", trainID, station, arrivalTime)
-	return n.Send(title, body)
+// track checks an emergency alert's receipt after the given delay, saving it
+// so tracking survives a restart. The caller must hold p.mu.
+func (p *Pushover) track(t *trackedAlert, after time.Duration) {
+	if p.closed {
+		return
+	}
+	if _, ok := p.tracking[t.Receipt]; !ok {
+		p.tracking[t.Receipt] = t
+		p.save()
+	}
+	p.timers[t.Receipt] = p.clock.AfterFunc(after, func() { p.check(t) })
 }
 
-func (n *Notifier) SendTrainDeparture(trainID, from, to, departureTime, platform string) error {
-	title := "Train Departed"
-	body := fmt.Sprintf("Train %s from %s to %s has departed at %s from Platform %s",
-		trainID, from, to, departureTime, platform)
-	return n.Send(title, body)
+// untrack stops tracking an alert. The caller must hold p.mu.
+func (p *Pushover) untrack(t *trackedAlert) {
+	delete(p.tracking, t.Receipt)
+	delete(p.timers, t.Receipt)
+	p.save()
 }
 
-func (n *Notifier) SendTrainCancellation(trainID, from, to, reason string) error {
-	title := "Train Cancellation Alert"
-	body := fmt.Sprintf("Train %s from %s to %s has been CANCELLED.\nReason: %s",
-		trainID, from, to, reason)
-	return n.SendWithPriority(title, body, PriorityHigh)
+// save writes the tracked alerts to the state file. The caller must hold p.mu.
+func (p *Pushover) save() {
+	alerts := make([]*trackedAlert, 0, len(p.tracking))
+	for _, t := range p.tracking {
+		alerts = append(alerts, t)
+	}
+	if err := p.state.Save(p.stateKey(), alerts); err != nil {
+		p.logger.WithField("error", err).Warn("failed to save emergency notification tracking")
+	}
 }
 
-func (n *Notifier) SendTubeDisruption(status, reason string) error {
-	title := "Tube Disruption Alert"
-	body := fmt.Sprintf("Northern Line: %s\n%s", status, reason)
-	return n.SendWithPriority(title, body, PriorityHigh)
+// check polls an emergency alert's receipt, escalating it if it has expired
+// or is still unacknowledged at its deadline, and otherwise checking again
+// after the retry interval.
+func (p *Pushover) check(t *trackedAlert) {
+	logger := p.logger.WithFields(logrus.Fields{
+		"title":   t.Title,
+		"receipt": t.Receipt,
+	})
+
+	details, err := p.app.GetReceiptDetails(t.Receipt)
+	if err != nil {
+		logger.WithField("error", err).Warn("failed to check pushover receipt")
+	}
+	acknowledged := err == nil && details.Acknowledged
+	if acknowledged {
+		logger.WithField("acknowledged_by", details.AcknowledgedBy).Info("emergency notification acknowledged")
+	}
+
+	now := p.clock.Now()
+	escalate := !acknowledged && ((err == nil && details.Expired) || !now.Before(t.Deadline))
+
+	if escalate {
+		p.escalateAlert(t, logger)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if acknowledged || escalate {
+		p.untrack(t)
+		return
+	}
+	if !p.closed {
+		p.timers[t.Receipt] = p.clock.AfterFunc(min(p.emergency.Retry, t.Deadline.Sub(now)), func() { p.check(t) })
+	}
 }
 
-func (n *Notifier) SendTubeStatus(status, reason string) error {
-	title := "Northern Line Status"
-	body := status
-	if reason != "" {
-		body = fmt.Sprintf("%s\n%s", status, reason)
+// escalateAlert passes an unacknowledged emergency alert on to the escalation
+// recipient, if there is one, cancelling the original so it stops repeating.
+func (p *Pushover) escalateAlert(t *trackedAlert, logger *logrus.Entry) {
+	if p.escalate == nil {
+		logger.Warn("emergency notification not acknowledged")
+		return
+	}
+
+	m := Message{
+		Title:    "Unacknowledged: " + t.Title,
+		Body:     t.Body,
+		URL:      t.URL,
+		Priority: PriorityEmergency,
+	}
+	resp, err := p.send(m, p.escalate)
+	if err != nil {
+		logger.WithField("error", err).Error("failed to escalate emergency notification")
+		return
+	}
+	if _, err := p.app.CancelEmergencyNotification(t.Receipt); err != nil {
+		logger.WithField("error", err).Warn("failed to cancel escalated emergency notification")
 	}
-	return n.Send(title, body)
+	logger.WithField("escalation_receipt", resp.Receipt).Warn("emergency notification not acknowledged, escalated")
 }
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// Notification types recorded in the journey history for held back messages.
+const (
+	historySuppressed = "suppressed"
+	historyDeferred   = "deferred"
+)
+
+// QuietHours is a recurring window in which a backend's normal priority
+// messages are held back. Window returns the window containing t, if any;
+// Defer sends the held back messages when it ends rather than dropping them.
+type QuietHours struct {
+	Window func(t time.Time) (start, end time.Time, ok bool)
+	Defer  bool
+}
+
+// DeferredError is returned for a message held back until a quiet window
+// ends, so the outbox can deliver it then. It does not count as a failure.
+type DeferredError struct {
+	Until time.Time
+}
+
+func (e *DeferredError) Error() string {
+	return "deferred until " + e.Until.Format("15:04")
+}
+
+// RetryAt returns when the message should be sent again.
+func (e *DeferredError) RetryAt() time.Time {
+	return e.Until
+}
+
+// Quiet wraps a backend, holding back normal priority messages during its
+// quiet hours and while it is muted. High priority messages always get
+// through. Held back messages are logged and recorded in the journey history;
+// those in a deferring window are returned as a *DeferredError to be sent
+// again when it ends, the rest are dropped.
+type Quiet struct {
+	recipient string
+	name      string
+	sender    Sender
+	windows   []QuietHours
+	mutesPath string
+	history   *history.Store
+	clock     clock.Clock
+	logger    *logrus.Logger
+}
+
+// NewQuiet wraps sender with the quiet hours of the named notifier and the
+// runtime mutes in the mutes file, if mutesPath is set. Recipient is the
+// notifier's recipient, or empty for a top-level notifier.
+func NewQuiet(recipient, name string, sender Sender, windows []QuietHours, mutesPath string, store *history.Store, clk clock.Clock, logger *logrus.Logger) *Quiet {
+	return &Quiet{
+		recipient: recipient,
+		name:      name,
+		sender:    sender,
+		windows:   windows,
+		mutesPath: mutesPath,
+		history:   store,
+		clock:     clk,
+		logger:    logger,
+	}
+}
+
+// Name returns the notifier's key in the mutes file, such as alice/pushover.
+func (q *Quiet) Name() string {
+	return NotifierKey(q.recipient, q.name)
+}
+
+// Unwrap returns the wrapped backend.
+func (q *Quiet) Unwrap() Sender {
+	return q.sender
+}
+
+func (q *Quiet) Send(m Message) error {
+	if m.Priority >= PriorityHigh {
+		return q.sender.Send(m)
+	}
+
+	now := q.clock.Now()
+	if until, muted := q.mutedUntil(now); muted {
+		q.holdBack(m, historySuppressed, "muted", now, until)
+		return nil
+	}
+	for _, w := range q.windows {
+		_, end, ok := w.Window(now)
+		if !ok {
+			continue
+		}
+		if w.Defer {
+			q.holdBack(m, historyDeferred, "quiet hours", now, end)
+			return &DeferredError{Until: end}
+		}
+		q.holdBack(m, historySuppressed, "quiet hours", now, end)
+		return nil
+	}
+
+	return q.sender.Send(m)
+}
+
+func (q *Quiet) mutedUntil(now time.Time) (time.Time, bool) {
+	if q.mutesPath == "" {
+		return time.Time{}, false
+	}
+	mutes, err := LoadMutes(q.mutesPath)
+	if err != nil {
+		q.logger.WithField("error", err).Warn("failed to read mutes, sending anyway")
+		return time.Time{}, false
+	}
+	return mutes.MutedUntil(q.recipient, q.name, now)
+}
+
+// holdBack logs and records a message that is not being sent now.
+func (q *Quiet) holdBack(m Message, outcome, reason string, now, until time.Time) {
+	q.logger.WithFields(logrus.Fields{
+		"notifier": NotifierKey(q.recipient, q.name),
+		"title":    m.Title,
+		"reason":   reason,
+		"until":    until.Format("15:04"),
+	}).Infof("notification %s", outcome)
+
+	n := history.Notification{Time: now, Type: outcome, Value: NotifierKey(q.recipient, q.name) + ":" + m.Kind}
+	date := now.Format(history.DateFormat)
+	if e, ok := m.Data.(events.ServiceEvent); ok {
+		svc := e.EventService()
+		n.Journey, n.ServiceUID = svc.Journey, svc.ServiceUID
+		if svc.RunDate != "" {
+			date = svc.RunDate
+		}
+	}
+	if err := q.history.RecordNotification(date, n); err != nil {
+		q.logger.WithField("error", err).Warn("failed to record held back notification")
+	}
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// Recipient is a person or group with their own backends. They receive the
+// alerts for the journeys they subscribe to, at or above their minimum
+// severity.
+type Recipient struct {
+	name        string
+	journeys    map[string]bool // nil for every journey
+	tube        bool            // whether any subscribed journey checks the Northern Line
+	minSeverity string
+	sender      Sender
+}
+
+// NewRecipient creates a recipient receiving alerts for journeys, or every
+// journey if journeys is empty, through sender. Tube is whether they receive
+// Northern Line alerts.
+func NewRecipient(name string, journeys []string, tube bool, minSeverity string, sender Sender) *Recipient {
+	r := &Recipient{
+		name:        name,
+		tube:        tube,
+		minSeverity: minSeverity,
+		sender:      sender,
+	}
+	if len(journeys) > 0 {
+		r.journeys = make(map[string]bool, len(journeys))
+		for _, j := range journeys {
+			r.journeys[j] = true
+		}
+	}
+	return r
+}
+
+// Wants reports whether the recipient should receive the message.
+func (r *Recipient) Wants(m Message) bool {
+	if severityRank[m.Severity()] < severityRank[r.minSeverity] {
+		return false
+	}
+	if e, ok := m.Data.(events.ServiceEvent); ok && r.journeys != nil {
+		return r.journeys[e.EventService().Journey]
+	}
+	switch m.Kind {
+	case KindTubeDisruption, KindTubeStatus:
+		return r.tube
+	}
+	return true
+}
+
+// Recipients fans each message out to the recipients that want it. If any
+// backend fails it returns a *SendError naming the failed backends.
+type Recipients []*Recipient
+
+func (rs Recipients) Send(m Message) error {
+	result := &SendError{Failed: make(map[string]error)}
+	for _, r := range rs {
+		if !r.Wants(m) {
+			continue
+		}
+		result.add(BackendName(r.sender, r.name), r.sender.Send(m))
+	}
+	return result.err()
+}
+
+// Route returns the names of the backends the message goes to.
+func (rs Recipients) Route(m Message) []string {
+	var names []string
+	for _, r := range rs {
+		if !r.Wants(m) {
+			continue
+		}
+		for _, b := range r.backends() {
+			names = append(names, b.name)
+		}
+	}
+	return names
+}
+
+// SendTo sends the message to the named backend only.
+func (rs Recipients) SendTo(backend string, m Message) error {
+	for _, r := range rs {
+		for _, b := range r.backends() {
+			if b.name == backend {
+				return b.sender.Send(m)
+			}
+		}
+	}
+	return fmt.Errorf("%w %q", ErrUnknownBackend, backend)
+}
+
+type namedSender struct {
+	name   string
+	sender Sender
+}
+
+// backends returns the recipient's senders with their names.
+func (r *Recipient) backends() []namedSender {
+	senders := []Sender{r.sender}
+	if multi, ok := r.sender.(Multi); ok {
+		senders = multi
+	}
+	named := make([]namedSender, len(senders))
+	for i, s := range senders {
+		fallback := r.name
+		if len(senders) > 1 {
+			fallback = NotifierKey(r.name, strconv.Itoa(i))
+		}
+		named[i] = namedSender{name: BackendName(s, fallback), sender: s}
+	}
+	return named
+}
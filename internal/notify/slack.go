@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Slack delivers messages to a Slack incoming webhook.
+type Slack struct {
+	httpClient *http.Client
+	webhookURL string
+	logger     *logrus.Logger
+}
+
+func NewSlack(webhookURL string, logger *logrus.Logger) *Slack {
+	return &Slack{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		webhookURL: webhookURL,
+		logger:     logger,
+	}
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Fallback  string       `json:"fallback"`
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text"`
+	Fields    []slackField `json:"fields,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackMessage builds the webhook payload for a message.
+func slackMessage(m Message) slackPayload {
+	attachment := slackAttachment{
+		Fallback:  m.Title + ": " + m.Body,
+		Color:     severityColors[m.Severity()],
+		Title:     m.Title,
+		TitleLink: m.URL,
+		Text:      m.Body,
+	}
+	for _, f := range m.Fields {
+		attachment.Fields = append(attachment.Fields, slackField{Title: f.Name, Value: f.Value, Short: true})
+	}
+	return slackPayload{
+		Text:        m.Title,
+		Attachments: []slackAttachment{attachment},
+	}
+}
+
+func (s *Slack) Send(m Message) error {
+	status, err := postJSON(s.httpClient, s.webhookURL, slackMessage(m))
+	if err != nil {
+		return fmt.Errorf("sending slack notification: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"title":  m.Title,
+		"status": status,
+	}).Debug("notification sent")
+
+	return nil
+}
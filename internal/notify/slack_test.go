@@ -0,0 +1,13 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSlackGolden(t *testing.T) {
+	testGolden(t, "slack", func(url string, logger *logrus.Logger) Sender {
+		return NewSlack(url, logger)
+	})
+}
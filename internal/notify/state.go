@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateFile keeps the state backends need across restarts, such as the
+// messages collected for an email digest, in a JSON file keyed by backend.
+//
+// A nil *StateFile is valid and keeps nothing.
+type StateFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewStateFile(path string) *StateFile {
+	return &StateFile{path: path}
+}
+
+// Load decodes the state saved under key into v, leaving v unchanged if there
+// is none.
+func (f *StateFile) Load(key string, v any) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.read()
+	if err != nil {
+		return err
+	}
+	data, ok := state[key]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing %s state: %w", key, err)
+	}
+	return nil
+}
+
+// Save replaces the state saved under key with v.
+func (f *StateFile) Save(key string, v any) error {
+	if f == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s state: %w", key, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.read()
+	if err != nil {
+		return err
+	}
+	state[key] = data
+	return f.write(state)
+}
+
+func (f *StateFile) read() (map[string]json.RawMessage, error) {
+	state := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state file: %w", err)
+	}
+	return state, nil
+}
+
+func (f *StateFile) write(state map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTelegramURL is the Telegram Bot API.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// telegramPollTimeout is how long each getUpdates request waits for updates.
+const telegramPollTimeout = 30 * time.Second
+
+// Telegram delivers messages to a chat through the Telegram Bot API, and
+// accepts commands sent to the bot.
+type Telegram struct {
+	httpClient *http.Client
+	apiURL     string
+	chatID     string
+	logger     *logrus.Logger
+}
+
+// CommandHandler handles a command sent to the bot, such as "status" for
+// "/status", and returns the reply.
+type CommandHandler func(ctx context.Context, command string, args []string) string
+
+func NewTelegram(baseURL, token, chatID string, logger *logrus.Logger) *Telegram {
+	if baseURL == "" {
+		baseURL = DefaultTelegramURL
+	}
+	return &Telegram{
+		httpClient: &http.Client{Timeout: telegramPollTimeout + 30*time.Second},
+		apiURL:     strings.TrimRight(baseURL, "/") + "/bot" + token,
+		chatID:     chatID,
+		logger:     logger,
+	}
+}
+
+// ChatID returns the chat the notifier sends to.
+func (t *Telegram) ChatID() string {
+	return t.chatID
+}
+
+func (t *Telegram) Send(m Message) error {
+	text := "<b>" + html.EscapeString(m.Title) + "</b>\n" + html.EscapeString(m.Body)
+	if m.URL != "" {
+		text += fmt.Sprintf("\n<a href=\"%s\">Realtime Trains</a>", html.EscapeString(m.URL))
+	}
+	if err := t.sendText(context.Background(), t.chatID, text, "HTML"); err != nil {
+		return fmt.Errorf("sending telegram notification: %w", err)
+	}
+
+	t.logger.WithField("title", m.Title).Debug("notification sent")
+	return nil
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+func (t *Telegram) sendText(ctx context.Context, chatID, text, parseMode string) error {
+	payload := map[string]string{
+		"chat_id": chatID,
+		"text":    text,
+	}
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
+	}
+	return t.call(ctx, "sendMessage", payload, nil)
+}
+
+// call invokes a Bot API method, decoding its result into result if set.
+// Errors never include the request URL, as it holds the bot token.
+func (t *Telegram) call(ctx context.Context, method string, payload any, result any) error {
+	var body strings.Builder
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return fmt.Errorf("encoding %s request: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL+"/"+method, strings.NewReader(body.String()))
+	if err != nil {
+		return fmt.Errorf("creating %s request: %w", method, withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing %s request: %w", method, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	var r telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decoding %s response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s failed (status %d): %s", method, resp.StatusCode, r.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return fmt.Errorf("decoding %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// withoutURL returns the underlying error of a *url.Error, dropping the URL.
+func withoutURL(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
+
+// Poll long-polls the bot for messages until ctx is cancelled, passing
+// commands from each chat in handlers to its handler and replying in that
+// chat with the result. Messages from other chats are ignored. A bot token
+// must only be polled once, so handlers covers every chat using the token.
+func (t *Telegram) Poll(ctx context.Context, handlers map[string]CommandHandler) {
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := t.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			t.logger.WithField("error", err).Warn("failed to poll telegram updates")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil {
+				continue
+			}
+			chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+			handle, ok := handlers[chatID]
+			if !ok {
+				continue
+			}
+			command, args, ok := parseCommand(u.Message.Text)
+			if !ok {
+				continue
+			}
+
+			t.logger.WithFields(logrus.Fields{
+				"chat":    chatID,
+				"command": command,
+				"args":    strings.Join(args, " "),
+			}).Info("received telegram command")
+
+			if reply := handle(ctx, command, args); reply != "" {
+				if err := t.sendText(ctx, chatID, reply, ""); err != nil {
+					t.logger.WithField("error", err).Warn("failed to reply to telegram command")
+				}
+			}
+		}
+	}
+}
+
+// parseCommand splits a bot command such as "/mute@trainpal_bot 2h" into its
+// name and arguments.
+func parseCommand(text string) (string, []string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+	command, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	return strings.ToLower(command), fields[1:], true
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const testTelegramToken = "123456:secret-token"
+
+func TestTelegramSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantErr  string
+	}{
+		{
+			name:     "delivered",
+			status:   http.StatusOK,
+			response: `{"ok":true,"result":{}}`,
+		},
+		{
+			name:     "error status",
+			status:   http.StatusBadRequest,
+			response: `{"ok":false,"description":"Bad Request: chat not found"}`,
+			wantErr:  "sendMessage failed (status 400): Bad Request: chat not found",
+		},
+		{
+			name:     "error status without a JSON body",
+			status:   http.StatusBadGateway,
+			response: `<html>Bad Gateway</html>`,
+			wantErr:  "decoding sendMessage response (status 502)",
+		},
+		{
+			name:     "not ok",
+			status:   http.StatusOK,
+			response: `{"ok":false,"description":"Forbidden: bot was blocked by the user"}`,
+			wantErr:  "sendMessage failed (status 200): Forbidden: bot was blocked by the user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var payload map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			err := NewTelegram(server.URL, testTelegramToken, "42", logger).Send(Message{
+				Title: "Train Delayed",
+				Body:  "The 0715 is 10 <min> late.",
+				URL:   "https://www.realtimetrains.co.uk/service/gb-nr:W12345/2025-01-06",
+			})
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			} else {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), testTelegramToken) {
+					t.Errorf("Send() error %q contains the bot token", err)
+				}
+			}
+
+			if want := "/bot" + testTelegramToken + "/sendMessage"; path != want {
+				t.Errorf("path = %s, want %s", path, want)
+			}
+			want := map[string]string{
+				"chat_id":    "42",
+				"text":       "<b>Train Delayed</b>\nThe 0715 is 10 &lt;min&gt; late.\n<a href=\"https://www.realtimetrains.co.uk/service/gb-nr:W12345/2025-01-06\">Realtime Trains</a>",
+				"parse_mode": "HTML",
+			}
+			for k, v := range want {
+				if payload[k] != v {
+					t.Errorf("%s = %q, want %q", k, payload[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramErrorOmitsToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	err := NewTelegram(server.URL, testTelegramToken, "42", logger).Send(Message{Title: "Title", Body: "Body"})
+	if err == nil {
+		t.Fatal("Send() to a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), testTelegramToken) {
+		t.Errorf("Send() error %q contains the bot token", err)
+	}
+}
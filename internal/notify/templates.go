@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+// Templates override the title and body of notifications, keyed by event kind.
+type Templates map[string]Template
+
+// Template is executed with the event to give a notification's title and
+// body. Either may be nil to keep the default wording.
+type Template struct {
+	Title *template.Template
+	Body  *template.Template
+}
+
+// apply rewrites the message's title and body from the event's templates, if
+// it has any. The message is unchanged if a template fails.
+func (t Templates) apply(e events.Event, msg *Message) error {
+	tmpl, ok := t[e.Kind()]
+	if !ok {
+		return nil
+	}
+	title, body := msg.Title, msg.Body
+	if tmpl.Title != nil {
+		var b strings.Builder
+		if err := tmpl.Title.Execute(&b, e); err != nil {
+			return fmt.Errorf("executing %s title template: %w", e.Kind(), err)
+		}
+		title = b.String()
+	}
+	if tmpl.Body != nil {
+		var b strings.Builder
+		if err := tmpl.Body.Execute(&b, e); err != nil {
+			return fmt.Errorf("executing %s body template: %w", e.Kind(), err)
+		}
+		body = b.String()
+	}
+	msg.Title, msg.Body = title, body
+	return nil
+}
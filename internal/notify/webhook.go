@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Webhook signature and metadata headers.
+const (
+	SignatureHeader = "X-Trainpal-Signature"
+	EventHeader     = "X-Trainpal-Event"
+	DeliveryHeader  = "X-Trainpal-Delivery"
+)
+
+// WebhookEvent is the JSON payload posted to webhooks. Data is the event's
+// details, such as the delay and platform of a delayed train.
+type WebhookEvent struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Severity string    `json:"severity"`
+	Priority int       `json:"priority"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	URL      string    `json:"url,omitempty"`
+	Data     any       `json:"data,omitempty"`
+}
+
+// Webhook posts every message as a signed JSON event to an HTTP endpoint.
+// The signature header is "sha256=" followed by the hex HMAC-SHA256 of the
+// request body keyed with the secret.
+type Webhook struct {
+	httpClient *http.Client
+	url        string
+	secret     []byte
+	logger     *logrus.Logger
+}
+
+// NewWebhook creates a webhook sender signing its requests with secret.
+func NewWebhook(url, secret string, logger *logrus.Logger) *Webhook {
+	return &Webhook{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		url:        url,
+		secret:     []byte(secret),
+		logger:     logger,
+	}
+}
+
+// Send makes a single delivery attempt. Failures are returned rather than
+// retried here, so the outbox can retry them without holding up other alerts.
+// The event ID is the message's, so it is the same on each attempt; a message
+// without one gets a new ID.
+func (w *Webhook) Send(m Message) error {
+	id := m.ID
+	if id == "" {
+		var err error
+		if id, err = newEventID(); err != nil {
+			return err
+		}
+	}
+	event := WebhookEvent{
+		ID:       id,
+		Type:     m.Kind,
+		Time:     time.Now(),
+		Severity: m.Severity(),
+		Priority: m.Priority,
+		Title:    m.Title,
+		Body:     m.Body,
+		URL:      m.URL,
+		Data:     m.Data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding webhook event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "trainpal/1.0")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(w.secret, body))
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending webhook event: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sending webhook event: unexpected status code: %d", resp.StatusCode)
+	}
+
+	w.logger.WithFields(logrus.Fields{
+		"title":    m.Title,
+		"event_id": event.ID,
+		"status":   resp.StatusCode,
+	}).Debug("notification sent")
+	return nil
+}
+
+// Sign returns the signature header value for a webhook body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating webhook event id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/events"
+)
+
+func TestWebhookSend(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	var deliveries []delivery
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries = append(deliveries, delivery{r.Header, body})
+	}))
+	defer server.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	webhook := NewWebhook(server.URL, "s3cret", logger)
+
+	e, _ := events.Example(events.KindTrainDelayed)
+	msg, _ := Format(e)
+	msg.ID = "0123456789abcdef"
+	for range 2 {
+		if err := webhook.Send(msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	for i, d := range deliveries {
+		if got, want := d.header.Get(SignatureHeader), Sign([]byte("s3cret"), d.body); got != want {
+			t.Errorf("delivery %d: %s = %q, want %q", i, SignatureHeader, got, want)
+		}
+		if got := d.header.Get(EventHeader); got != events.KindTrainDelayed {
+			t.Errorf("delivery %d: %s = %q, want %q", i, EventHeader, got, events.KindTrainDelayed)
+		}
+		if got := d.header.Get(DeliveryHeader); got != msg.ID {
+			t.Errorf("delivery %d: %s = %q, want %q", i, DeliveryHeader, got, msg.ID)
+		}
+
+		var got struct {
+			WebhookEvent
+			Data events.TrainDelayed `json:"data"`
+		}
+		if err := json.Unmarshal(d.body, &got); err != nil {
+			t.Fatalf("delivery %d: decoding body: %v", i, err)
+		}
+		if got.ID != msg.ID {
+			t.Errorf("delivery %d: id = %q, want %q", i, got.ID, msg.ID)
+		}
+		if got.Type != msg.Kind || got.Severity != msg.Severity() || got.Priority != msg.Priority ||
+			got.Title != msg.Title || got.Body != msg.Body || got.URL != msg.URL {
+			t.Errorf("delivery %d: event = %+v, want fields of %+v", i, got.WebhookEvent, msg)
+		}
+		if got.Data.ServiceUID != e.(events.TrainDelayed).ServiceUID || got.Data.DelayMinutes != e.(events.TrainDelayed).DelayMinutes {
+			t.Errorf("delivery %d: data = %+v, want %+v", i, got.Data, e)
+		}
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(deliveries))
+	}
+}
+
+func TestWebhookSendWithoutID(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(DeliveryHeader))
+	}))
+	defer server.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	webhook := NewWebhook(server.URL, "s3cret", logger)
+	for range 2 {
+		if err := webhook.Send(Message{Title: "Title", Body: "Body"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] == ids[1] {
+		t.Errorf("delivery ids = %q, want two different ids", ids)
+	}
+}
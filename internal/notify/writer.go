@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/danpilch/trainpal/internal/clock"
+)
+
+// Writer prints messages as text, for replays and dry runs.
+type Writer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	clock clock.Clock
+}
+
+// NewWriter prints each message to w, stamped with the clock's time.
+func NewWriter(w io.Writer, clk clock.Clock) *Writer {
+	return &Writer{
+		w:     w,
+		clock: clk,
+	}
+}
+
+func (w *Writer) Send(m Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %-20s %s\n", w.clock.Now().Format("2006-01-02 15:04"), m.Kind, m.Title)
+	for _, line := range strings.Split(m.Body, "\n") {
+		fmt.Fprintf(&b, "%38s%s\n", "", line)
+	}
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
@@ -0,0 +1,454 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// Delivery retries. Each retry waits twice as long as the last, up to
+// maxBackoff, until the event is older than maxAge.
+const (
+	initialBackoff = 10 * time.Second
+	maxBackoff     = 10 * time.Minute
+	maxAge         = 2 * time.Hour
+)
+
+// Entry is an event waiting to be delivered to one backend. An event going to
+// several backends has an entry for each, sharing its ID, so each is retried
+// on its own.
+type Entry struct {
+	ID          string           `json:"id"`
+	Backend     string           `json:"backend"`
+	Queued      time.Time        `json:"queued"`
+	Kind        string           `json:"kind"`
+	Event       json.RawMessage  `json:"event"`
+	Sent        *events.Notified `json:"sent,omitempty"` // published once delivered to any backend
+	Attempts    int              `json:"attempts,omitempty"`
+	NextAttempt time.Time        `json:"next_attempt"`
+	Deferred    time.Time        `json:"deferred,omitzero"` // held back by the backend until then
+	LastError   string           `json:"last_error,omitempty"`
+}
+
+// deferral is a delivery error asking for the delivery to be made again
+// later, such as a backend's quiet hours. It is not a failure, so the entry is
+// retried at RetryAt without backing off.
+type deferral interface {
+	error
+	RetryAt() time.Time
+}
+
+// Deliverer sends events to notification backends by name.
+type Deliverer interface {
+	// Backends returns the backends the event should be delivered to.
+	Backends(e events.Event) []string
+	// Deliver sends the event to one backend. id identifies the event's
+	// notification and is the same on every attempt.
+	Deliver(backend, id string, e events.Event) error
+}
+
+// Outbox is a persistent queue of events. Events are published as soon as
+// they arrive and written to a file with an entry for each backend they go
+// to. Run hands the entries to the deliverer, retrying each with exponential
+// backoff until it is delivered or stale, so an alert is not lost when a
+// notification backend is unreachable or trainpal restarts, and one failing
+// backend does not repeat the alert on the others.
+type Outbox struct {
+	path      string
+	deliverer Deliverer
+	publish   events.Handler
+	history   *history.Store
+	clock     clock.Clock
+	logger    *logrus.Logger
+	wake      chan struct{}
+
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// Open opens the outbox file at path, keeping any events left undelivered by a
+// previous run. A missing file is an empty outbox. Events are delivered
+// through deliverer and published, along with the record of each sent
+// notification, to publish. Retries and staleness are timed by clk.
+func Open(path string, deliverer Deliverer, publish events.Handler, store *history.Store, clk clock.Clock, logger *logrus.Logger) (*Outbox, error) {
+	o := &Outbox{
+		path:      path,
+		deliverer: deliverer,
+		publish:   publish,
+		history:   store,
+		clock:     clk,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading outbox file: %w", err)
+	}
+	if err := json.Unmarshal(data, &o.entries); err != nil {
+		return nil, fmt.Errorf("parsing outbox file: %w", err)
+	}
+	if len(o.entries) > 0 {
+		logger.WithField("pending", len(o.entries)).Info("resuming undelivered notifications")
+	}
+	return o, nil
+}
+
+// Publish publishes an event and queues it for delivery to each backend that
+// wants it. Once it is delivered to any of them, sent (if set) is published
+// too, so the journey history records it. An event whose notification is
+// already queued is not queued again. Records are only published, as they
+// just update the history.
+func (o *Outbox) Publish(e events.Event, sent *events.Notified) error {
+	if _, ok := e.(events.Record); ok {
+		return o.publish(e)
+	}
+	if o.queued(sent) {
+		return nil
+	}
+	queued, err := o.enqueue(e, sent)
+	if err != nil {
+		return err
+	}
+	if err := o.publish(e); err != nil {
+		o.logger.WithFields(logrus.Fields{
+			"kind":  e.Kind(),
+			"error": err,
+		}).Warn("failed to publish event")
+	}
+	if queued {
+		select {
+		case o.wake <- struct{}{}:
+		default:
+		}
+	} else if sent != nil {
+		// no backend wants the alert, so there is nothing left to deliver
+		return o.publish(*sent)
+	}
+	return nil
+}
+
+// queued reports whether the notification sent is already waiting to be
+// delivered.
+func (o *Outbox) queued(sent *events.Notified) bool {
+	if sent == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, entry := range o.entries {
+		if entry.Sent != nil && *entry.Sent == *sent {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue saves an entry for each backend the event goes to, reporting
+// whether any were queued.
+func (o *Outbox) enqueue(e events.Event, sent *events.Notified) (bool, error) {
+	backends := o.deliverer.Backends(e)
+	if len(backends) == 0 {
+		return false, nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return false, fmt.Errorf("encoding %s event: %w", e.Kind(), err)
+	}
+	id, err := newID()
+	if err != nil {
+		return false, err
+	}
+	now := o.clock.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(o.entries)
+	for _, backend := range backends {
+		o.entries = append(o.entries, &Entry{
+			ID:          id,
+			Backend:     backend,
+			Queued:      now,
+			Kind:        e.Kind(),
+			Event:       data,
+			Sent:        sent,
+			NextAttempt: now,
+		})
+	}
+	if err := o.save(); err != nil {
+		o.entries = o.entries[:n]
+		return false, err
+	}
+	return true, nil
+}
+
+// Pending returns the number of deliveries still to be made.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Run delivers queued events until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context) {
+	due := make(chan struct{}, 1)
+	timer := o.clock.AfterFunc(maxBackoff, func() {
+		select {
+		case due <- struct{}{}:
+		default:
+		}
+	})
+	defer timer.Stop()
+
+	for {
+		if wait, ok := o.deliverDue(); ok {
+			timer.Reset(wait)
+		} else {
+			timer.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-due:
+		}
+	}
+}
+
+// deliverDue attempts every entry that is due, returning how long until the
+// next retry, if any entries are left.
+func (o *Outbox) deliverDue() (time.Duration, bool) {
+	now := o.clock.Now()
+
+	o.mu.Lock()
+	var due []*Entry
+	for _, entry := range o.entries {
+		if !entry.NextAttempt.After(now) {
+			due = append(due, entry)
+		}
+	}
+	o.mu.Unlock()
+
+	for _, entry := range due {
+		o.attempt(entry)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.entries) == 0 {
+		return 0, false
+	}
+	next := o.entries[0].NextAttempt
+	for _, entry := range o.entries[1:] {
+		if entry.NextAttempt.Before(next) {
+			next = entry.NextAttempt
+		}
+	}
+	return max(next.Sub(o.clock.Now()), 0), true
+}
+
+// attempt delivers an entry, dropping it once it is delivered or stale and
+// otherwise scheduling a retry.
+func (o *Outbox) attempt(entry *Entry) {
+	logger := o.logger.WithFields(logrus.Fields{
+		"id":       entry.ID,
+		"backend":  entry.Backend,
+		"kind":     entry.Kind,
+		"queued":   entry.Queued.Format(time.TimeOnly),
+		"attempts": entry.Attempts,
+	})
+
+	e, err := events.Decode(entry.Kind, entry.Event)
+	if err != nil {
+		logger.WithField("error", err).Error("dropping undecodable notification")
+		o.remove(entry)
+		return
+	}
+	if reason := o.stale(entry, e); reason != "" {
+		logger.WithFields(logrus.Fields{
+			"reason":     reason,
+			"last_error": entry.LastError,
+		}).Warn("dropping stale notification")
+		o.remove(entry)
+		return
+	}
+
+	err = o.deliverer.Deliver(entry.Backend, entry.ID, e)
+	var deferred deferral
+	if errors.As(err, &deferred) {
+		o.mu.Lock()
+		entry.Deferred = deferred.RetryAt()
+		entry.NextAttempt = entry.Deferred
+		saveErr := o.save()
+		o.mu.Unlock()
+
+		logger.WithField("until", entry.Deferred.Format(time.TimeOnly)).Info("notification deferred")
+		if saveErr != nil {
+			logger.WithField("error", saveErr).Warn("failed to save outbox")
+		}
+		return
+	}
+	if err != nil {
+		o.mu.Lock()
+		entry.Attempts++
+		entry.LastError = err.Error()
+		entry.NextAttempt = o.clock.Now().Add(backoff(entry.Attempts))
+		saveErr := o.save()
+		o.mu.Unlock()
+
+		logger.WithFields(logrus.Fields{
+			"error":        err,
+			"next_attempt": entry.NextAttempt.Format(time.TimeOnly),
+		}).Warn("notification delivery failed, will retry")
+		if saveErr != nil {
+			logger.WithField("error", saveErr).Warn("failed to save outbox")
+		}
+		return
+	}
+
+	sent := o.delivered(entry)
+	if entry.Attempts > 0 {
+		logger.Info("notification delivered after retrying")
+	}
+	if sent != nil {
+		if err := o.publish(*sent); err != nil {
+			logger.WithField("error", err).Warn("failed to record notification history")
+		}
+	}
+}
+
+// delivered removes a delivered entry. It returns the record of the sent
+// notification if this is the first of the event's entries to be delivered,
+// clearing it from the others so it is only published once.
+func (o *Outbox) delivered(entry *Entry) *events.Notified {
+	o.mu.Lock()
+	sent := entry.Sent
+	if sent != nil {
+		for _, e := range o.entries {
+			if e.ID == entry.ID {
+				e.Sent = nil
+			}
+		}
+	}
+	o.mu.Unlock()
+	o.remove(entry)
+	return sent
+}
+
+// stale returns why an entry is no longer worth delivering, or "" if it still
+// is. Alerts about a service are stale once the service has arrived, apart
+// from the arrival alerts themselves; any alert is stale maxAge after it was
+// queued, or after its deferral ended.
+func (o *Outbox) stale(entry *Entry, e events.Event) string {
+	due := entry.Queued
+	if entry.Deferred.After(due) {
+		due = entry.Deferred
+	}
+	if o.clock.Now().Sub(due) > maxAge {
+		return "too old"
+	}
+	switch e.Kind() {
+	case events.KindTrainArrived, events.KindDelayRepayEligible:
+		return ""
+	}
+	if se, ok := e.(events.ServiceEvent); ok {
+		svc := se.EventService()
+		if recorded, ok := o.history.Service(svc.RunDate, svc.ServiceUID); ok && recorded.Arrived {
+			return "service has arrived"
+		}
+	}
+	return ""
+}
+
+func (o *Outbox) remove(entry *Entry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, e := range o.entries {
+		if e == entry {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			break
+		}
+	}
+	if err := o.save(); err != nil {
+		o.logger.WithField("error", err).Warn("failed to save outbox")
+	}
+}
+
+// save replaces the outbox file with the queued entries. The caller must hold
+// o.mu.
+func (o *Outbox) save() error {
+	data, err := json.MarshalIndent(o.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding outbox: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".outbox-*")
+	if err != nil {
+		return fmt.Errorf("writing outbox file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing outbox file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing outbox file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("writing outbox file: %w", err)
+	}
+	return nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := initialBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating outbox id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Direct delivers events as soon as they are published, without queueing or
+// retrying them, for replays.
+type Direct struct {
+	deliver events.Handler
+}
+
+// NewDirect creates a publisher delivering straight to deliver.
+func NewDirect(deliver events.Handler) *Direct {
+	return &Direct{deliver: deliver}
+}
+
+func (d *Direct) Publish(e events.Event, sent *events.Notified) error {
+	if err := d.deliver(e); err != nil {
+		return err
+	}
+	if sent == nil {
+		return nil
+	}
+	return d.deliver(*sent)
+}
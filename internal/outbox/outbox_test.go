@@ -0,0 +1,256 @@
+package outbox
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+var start = time.Date(2025, 1, 6, 7, 0, 0, 0, time.UTC)
+
+// fakeDeliverer delivers every event to its backends, failing for those set in
+// failing.
+type fakeDeliverer struct {
+	clock    *clock.Fake
+	backends []string
+	failing  map[string]bool
+
+	attempts []attempt
+}
+
+type attempt struct {
+	Backend string
+	ID      string
+	Kind    string
+	At      time.Time
+}
+
+func (d *fakeDeliverer) Backends(events.Event) []string {
+	return d.backends
+}
+
+func (d *fakeDeliverer) Deliver(backend, id string, e events.Event) error {
+	d.attempts = append(d.attempts, attempt{Backend: backend, ID: id, Kind: e.Kind(), At: d.clock.Now()})
+	if d.failing[backend] {
+		return errors.New("backend unreachable")
+	}
+	return nil
+}
+
+// published collects the events an outbox publishes.
+type published struct {
+	events []events.Event
+}
+
+func (p *published) handle(e events.Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+// sent returns the notification records published.
+func (p *published) sent() []events.Notified {
+	var sent []events.Notified
+	for _, e := range p.events {
+		if n, ok := e.(events.Notified); ok {
+			sent = append(sent, n)
+		}
+	}
+	return sent
+}
+
+type testOutbox struct {
+	*Outbox
+	path      string
+	sim       *clock.Fake
+	deliverer *fakeDeliverer
+	published *published
+}
+
+// openTest opens an outbox in a new directory, delivering to backends "a" and
+// "b" on a fake clock.
+func openTest(t *testing.T, store *history.Store) *testOutbox {
+	t.Helper()
+	sim := clock.NewFake(start)
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	return reopen(t, path, sim, store, &fakeDeliverer{clock: sim, backends: []string{"a", "b"}})
+}
+
+func reopen(t *testing.T, path string, sim *clock.Fake, store *history.Store, deliverer *fakeDeliverer) *testOutbox {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p := &published{}
+	o, err := Open(path, deliverer, p.handle, store, sim, logger)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return &testOutbox{Outbox: o, path: path, sim: sim, deliverer: deliverer, published: p}
+}
+
+func example(t *testing.T, kind string) events.Event {
+	t.Helper()
+	e, ok := events.Example(kind)
+	if !ok {
+		t.Fatalf("no example %s event", kind)
+	}
+	return e
+}
+
+var sentDelay = &events.Notified{Date: "2025-01-06", Journey: "morning", ServiceUID: "W12345", Type: "delay", Value: "10"}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		80 * time.Second,
+		160 * time.Second,
+		320 * time.Second,
+		10 * time.Minute,
+		10 * time.Minute,
+	}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	o := openTest(t, nil)
+	o.deliverer.backends = []string{"a"}
+	o.deliverer.failing = map[string]bool{"a": true}
+
+	if err := o.Publish(example(t, events.KindTrainDelayed), sentDelay); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := o.Pending(); got != 1 {
+		t.Fatalf("Pending() = %d, want 1", got)
+	}
+
+	// Run the outbox's loop by hand, moving the clock to each retry.
+	for range 5 {
+		wait, ok := o.deliverDue()
+		if !ok {
+			t.Fatal("entry dropped while retrying")
+		}
+		o.sim.Advance(wait)
+	}
+	o.deliverer.failing = nil
+	if _, ok := o.deliverDue(); ok {
+		t.Fatalf("Pending() = %d after delivering, want 0", o.Pending())
+	}
+
+	var times []time.Duration
+	for _, a := range o.deliverer.attempts {
+		times = append(times, a.At.Sub(start))
+	}
+	want := []time.Duration{0, 10 * time.Second, 30 * time.Second, 70 * time.Second, 150 * time.Second, 310 * time.Second}
+	if !slices.Equal(times, want) {
+		t.Errorf("attempts after %v, want %v", times, want)
+	}
+	for _, a := range o.deliverer.attempts {
+		if a.ID != o.deliverer.attempts[0].ID {
+			t.Errorf("attempt IDs %q and %q differ, want the same on every retry", a.ID, o.deliverer.attempts[0].ID)
+		}
+	}
+	if got := o.published.sent(); !slices.Equal(got, []events.Notified{*sentDelay}) {
+		t.Errorf("published sent records %+v, want %+v", got, *sentDelay)
+	}
+}
+
+func TestOutboxDropsStale(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), logger)
+	if err != nil {
+		t.Fatalf("history.Open() error = %v", err)
+	}
+	defer store.Close()
+
+	t.Run("too old", func(t *testing.T) {
+		o := openTest(t, store)
+		o.deliverer.backends = []string{"a"}
+		o.deliverer.failing = map[string]bool{"a": true}
+		o.Publish(example(t, events.KindTrainDelayed), sentDelay)
+		o.deliverDue()
+
+		o.sim.Advance(maxAge + time.Second)
+		if _, ok := o.deliverDue(); ok {
+			t.Errorf("Pending() = %d, want the stale entry dropped", o.Pending())
+		}
+		if n := len(o.deliverer.attempts); n != 1 {
+			t.Errorf("%d attempts, want 1 before the entry went stale", n)
+		}
+		if got := o.published.sent(); len(got) != 0 {
+			t.Errorf("published sent records %+v for a dropped alert, want none", got)
+		}
+	})
+
+	t.Run("service arrived", func(t *testing.T) {
+		o := openTest(t, store)
+		o.deliverer.backends = []string{"a"}
+		o.deliverer.failing = map[string]bool{"a": true}
+		o.Publish(example(t, events.KindTrainDelayed), sentDelay)
+		o.Publish(example(t, events.KindTrainArrived), nil)
+		o.deliverDue()
+
+		// The service arrives while the backend is down.
+		err := store.RecordService("2025-01-06", history.Observation{ServiceUID: "W12345", Arrived: true, ActualArrival: "0750"})
+		if err != nil {
+			t.Fatalf("RecordService() error = %v", err)
+		}
+		o.deliverer.failing = nil
+		o.deliverer.attempts = nil
+		o.sim.Advance(initialBackoff)
+		if _, ok := o.deliverDue(); ok {
+			t.Errorf("Pending() = %d, want none left", o.Pending())
+		}
+
+		var delivered []string
+		for _, a := range o.deliverer.attempts {
+			delivered = append(delivered, a.Kind)
+		}
+		if want := []string{events.KindTrainArrived}; !slices.Equal(delivered, want) {
+			t.Errorf("delivered %v, want %v with the delay alert dropped", delivered, want)
+		}
+	})
+}
+
+func TestOutboxReloadsPending(t *testing.T) {
+	o := openTest(t, nil)
+	o.deliverer.failing = map[string]bool{"b": true}
+	if err := o.Publish(example(t, events.KindTrainDelayed), sentDelay); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	o.deliverDue()
+	if got := o.published.sent(); !slices.Equal(got, []events.Notified{*sentDelay}) {
+		t.Fatalf("published sent records %+v, want %+v once a is delivered", got, *sentDelay)
+	}
+
+	// Restart with b working again.
+	o.sim.Advance(initialBackoff)
+	restarted := reopen(t, o.path, o.sim, nil, &fakeDeliverer{clock: o.sim, backends: []string{"a", "b"}})
+	if got := restarted.Pending(); got != 1 {
+		t.Fatalf("Pending() after reopening = %d, want 1", got)
+	}
+	if _, ok := restarted.deliverDue(); ok {
+		t.Errorf("Pending() = %d, want 0", restarted.Pending())
+	}
+	got := restarted.deliverer.attempts
+	if len(got) != 1 || got[0].Backend != "b" || got[0].ID != o.deliverer.attempts[0].ID {
+		t.Errorf("attempts after reopening = %+v, want b with the original ID %s", got, o.deliverer.attempts[0].ID)
+	}
+	if sent := restarted.published.sent(); len(sent) != 0 {
+		t.Errorf("published sent records %+v again after reopening, want none", sent)
+	}
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+)
+
+// taskQueue is a min-heap of tasks ordered by time. Tasks due at the same time
+// run in the order they were added.
+type taskQueue []*Task
+
+func (q taskQueue) Len() int { return len(q) }
+
+func (q taskQueue) Less(i, j int) bool {
+	if q[i].Time.Equal(q[j].Time) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].Time.Before(q[j].Time)
+}
+
+func (q taskQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *taskQueue) Push(x any) { *q = append(*q, x.(*Task)) }
+
+func (q *taskQueue) Pop() any {
+	old := *q
+	task := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return task
+}
+
+// popDue removes and returns the tasks due by now, earliest first.
+func (q *taskQueue) popDue(now time.Time) []*Task {
+	var due []*Task
+	for len(*q) > 0 && !(*q)[0].Time.After(now) {
+		due = append(due, heap.Pop(q).(*Task))
+	}
+	return due
+}
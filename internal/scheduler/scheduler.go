@@ -1,12 +1,16 @@
 package scheduler
 
 import (
+	"cmp"
+	"container/heap"
 	"context"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/danpilch/trainpal/internal/clock"
 	"github.com/danpilch/trainpal/internal/config"
 	"github.com/danpilch/trainpal/internal/monitor"
 )
@@ -14,58 +18,132 @@ import (
 type TaskType int
 
 const (
-	TaskMorningDelayCheck TaskType = iota
-	TaskEveningDelayCheck
-	TaskMorningArrivalCheck
-	TaskEveningArrivalCheck
+	TaskDelayCheck TaskType = iota
+	TaskStatusUpdate
+	TaskDepartureCheck
+	TaskArrivalCheck
 	TaskNorthernLineCheck
 	TaskNorthernLineSummary
-	TaskMorningStatusUpdate
-	TaskEveningStatusUpdate
-	TaskMorningDepartureCheck
-	TaskEveningDepartureCheck
+	TaskConnectionCheck
+	TaskPlatformCheck
+	TaskArrivalDelayCheck
 )
 
+func (t TaskType) String() string {
+	switch t {
+	case TaskDelayCheck:
+		return "delay_check"
+	case TaskStatusUpdate:
+		return "status_update"
+	case TaskDepartureCheck:
+		return "departure_check"
+	case TaskArrivalCheck:
+		return "arrival_check"
+	case TaskNorthernLineCheck:
+		return "northern_line_check"
+	case TaskNorthernLineSummary:
+		return "northern_line_summary"
+	case TaskConnectionCheck:
+		return "connection_check"
+	case TaskPlatformCheck:
+		return "platform_check"
+	case TaskArrivalDelayCheck:
+		return "arrival_delay_check"
+	default:
+		return "unknown"
+	}
+}
+
 type Task struct {
 	Type      TaskType
+	Journey   *config.TrainConfig // nil for tasks not tied to a journey
+	Leg       config.Leg          // the train this task checks
+	Feeder    config.Leg          // for connection checks, the leg arriving at Leg.From
 	Time      time.Time
-	Executed  bool
 	Repeating bool
+	Every     time.Duration // how often a repeating task runs
+
+	seq uint64 // the order tasks were queued, to run tasks due together in order
 }
 
+// Timing of the scheduler's timer.
+const (
+	// lateTolerance is how late a task can run and still count as on time.
+	// Tasks any later were missed, and run according to the catch-up policy.
+	lateTolerance = 2 * time.Minute
+
+	// maxSleep is the longest the scheduler sleeps. Timers do not count time
+	// the machine spends suspended, so this bounds how long after a resume
+	// the scheduler notices it has missed tasks.
+	maxSleep = 5 * time.Minute
+
+	// minSuspend is how far the wall clock must run ahead of the monotonic
+	// clock between ticks to count as the machine having been suspended,
+	// rather than the wall clock being adjusted.
+	minSuspend = lateTolerance
+)
+
 type Scheduler struct {
 	cfg          *config.Config
 	trainMonitor *monitor.TrainMonitor
 	tubeMonitor  *monitor.TubeMonitor
+	clock        clock.Clock
 	logger       *logrus.Logger
 
 	mu             sync.Mutex
-	tasks          []Task
+	tasks          taskQueue
+	seq            uint64
 	currentDay     int
+	lastTick       time.Time // with a monotonic reading on the real clock
 	arrivalPolling map[TaskType]bool
+	timer          clock.Timer
+	stopped        bool
 	stopCh         chan struct{}
 	wg             sync.WaitGroup
+
+	runMu sync.Mutex // held while running tasks
 }
 
 func NewScheduler(
 	cfg *config.Config,
 	trainMonitor *monitor.TrainMonitor,
 	tubeMonitor *monitor.TubeMonitor,
+	clk clock.Clock,
 	logger *logrus.Logger,
 ) *Scheduler {
 	return &Scheduler{
 		cfg:            cfg,
 		trainMonitor:   trainMonitor,
 		tubeMonitor:    tubeMonitor,
+		clock:          clk,
 		logger:         logger,
 		arrivalPolling: make(map[TaskType]bool),
 		stopCh:         make(chan struct{}),
 	}
 }
 
+// Start sets up today's tasks and runs each when it is due on the scheduler's
+// clock, until ctx is cancelled or Stop is called.
 func (s *Scheduler) Start(ctx context.Context) {
+	s.SetupDailyTasks()
+
+	s.mu.Lock()
+	s.lastTick = s.clock.Now()
+	s.timer = s.clock.AfterFunc(maxSleep, func() { s.wake(ctx) })
+	s.rearm()
+	s.mu.Unlock()
+
 	s.wg.Add(1)
-	go s.run(ctx)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-ctx.Done():
+			s.logger.Info("scheduler stopped: context cancelled")
+		case <-s.stopCh:
+			s.logger.Info("scheduler stopped: stop signal received")
+		}
+		s.halt()
+	}()
 }
 
 func (s *Scheduler) Stop() {
@@ -73,277 +151,429 @@ func (s *Scheduler) Stop() {
 	s.wg.Wait()
 }
 
-func (s *Scheduler) run(ctx context.Context) {
-	defer s.wg.Done()
+// wake runs the due tasks when the timer fires, unless the scheduler has
+// stopped.
+func (s *Scheduler) wake(ctx context.Context) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
 
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if !stopped {
+		s.Tick(ctx)
+	}
+}
 
-	s.setupDailyTasks()
+// halt stops the timer, waiting for any tasks running to finish.
+func (s *Scheduler) halt() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
 
-	for {
-		select {
-		case <-ctx.Done():
-			s.logger.Info("scheduler stopped: context cancelled")
-			return
-		case <-s.stopCh:
-			s.logger.Info("scheduler stopped: stop signal received")
-			return
-		case <-ticker.C:
-			s.tick(ctx)
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.timer.Stop()
+}
+
+// Add schedules a task, waking the scheduler early if it is due before the
+// next task. Tasks are for the current day, so an added task not yet run is
+// dropped with the rest when the day changes.
+func (s *Scheduler) Add(task Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.push(&task)
+	s.rearm()
+}
+
+// push queues a task. The caller must hold s.mu.
+func (s *Scheduler) push(task *Task) {
+	s.seq++
+	task.seq = s.seq
+	heap.Push(&s.tasks, task)
+}
+
+// rearm sets the timer for the next task, the start of the next day or
+// maxSleep, whichever is soonest. The caller must hold s.mu.
+func (s *Scheduler) rearm() {
+	if s.timer == nil || s.stopped {
+		return
+	}
+	now := s.clock.Now()
+	next := now.Add(maxSleep)
+	y, m, d := now.Date()
+	if midnight := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()); midnight.Before(next) {
+		next = midnight
 	}
+	if len(s.tasks) > 0 && s.tasks[0].Time.Before(next) {
+		next = s.tasks[0].Time
+	}
+	s.timer.Reset(max(next.Sub(now), 0))
 }
 
-func (s *Scheduler) tick(ctx context.Context) {
-	now := time.Now()
+// Tick runs the tasks due at the clock's current time, setting up the day's
+// tasks first if the day has changed, and requeues the repeating ones.
+//
+// If the machine was suspended since the last tick, the tasks missed while it
+// was run according to the catch-up policy, including any from the previous
+// day and from the new day's tasks if it was suspended over midnight.
+func (s *Scheduler) Tick(ctx context.Context) {
+	now := s.clock.Now()
 
+	s.mu.Lock()
+	var suspended time.Duration
+	if !s.lastTick.IsZero() {
+		if drift := s.clock.Drift(s.lastTick, now); drift >= minSuspend {
+			suspended = drift
+		}
+	}
+	since := s.lastTick
+	s.lastTick = now
+	if suspended > 0 {
+		s.logger.WithField("suspended", suspended.Round(time.Second).String()).Warn("resumed after suspend")
+	}
+
+	var missed []*Task
 	if now.Day() != s.currentDay {
 		s.logger.Info("day changed, resetting tasks")
 		s.trainMonitor.ResetNotificationState()
 		s.tubeMonitor.ResetNotificationState()
-		s.setupDailyTasks()
+		if suspended > 0 {
+			// Polls are for the previous day's trains and stop with it,
+			// but its one-off checks were missed and are caught up.
+			for _, task := range s.tasks.popDue(now) {
+				if !task.Repeating {
+					missed = append(missed, task)
+				}
+			}
+		} else {
+			since = now
+		}
+		s.setupDay(now, since)
+	}
+
+	due := s.catchUp(append(missed, s.tasks.popDue(now)...), now, suspended > 0)
+	s.mu.Unlock()
+
+	for _, task := range due {
+		s.executeTask(ctx, task)
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-
-	for i := range s.tasks {
-		task := &s.tasks[i]
-		if task.Executed && !task.Repeating {
+	for _, task := range due {
+		if !task.Repeating {
 			continue
 		}
+		// A poll that was missed runs once, not once for every interval missed
+		if !task.Time.After(now) {
+			task.Time = now.Add(task.Every)
+		}
+		s.push(task)
+	}
+	s.rearm()
+}
+
+// taskKey identifies the tasks that check the same thing at different times.
+type taskKey struct {
+	Type    TaskType
+	Journey string
+	Leg     config.Leg
+}
 
-		if s.isWithinWindow(task.Time, now, 2*time.Minute) {
-			s.executeTask(ctx, task)
+func (t *Task) key() taskKey {
+	k := taskKey{Type: t.Type, Leg: t.Leg}
+	if t.Journey != nil {
+		k.Journey = t.Journey.Name
+	}
+	return k
+}
+
+// catchUp returns which of the due tasks to run. Tasks due within
+// lateTolerance run, as do repeating tasks, and tasks running late because
+// earlier ones took a while. Tasks missed by longer because the machine was
+// suspended run according to the catch-up policy.
+func (s *Scheduler) catchUp(due []*Task, now time.Time, suspended bool) []*Task {
+	policy := s.cfg.CatchUpPolicy()
+	missed := func(task *Task) bool {
+		return suspended && !task.Repeating && now.Sub(task.Time) > lateTolerance
+	}
+
+	latest := make(map[taskKey]*Task)
+	var late time.Duration
+	for _, task := range due {
+		if missed(task) {
+			latest[task.key()] = task
+			late = max(late, now.Sub(task.Time))
+		}
+	}
+	if len(latest) == 0 {
+		return due
+	}
+
+	var run []*Task
+	skipped := 0
+	for _, task := range due {
+		switch {
+		case !missed(task), policy == config.CatchUpAll:
+		case policy == config.CatchUpLatest && latest[task.key()] == task:
+		default:
+			skipped++
+			continue
 		}
+		run = append(run, task)
 	}
+	s.logger.WithFields(logrus.Fields{
+		"policy":  policy,
+		"late":    late.Round(time.Second).String(),
+		"skipped": skipped,
+	}).Warn("catching up on missed tasks")
+	return run
 }
 
-func (s *Scheduler) isWithinWindow(taskTime, now time.Time, window time.Duration) bool {
-	diff := now.Sub(taskTime)
-	return diff >= 0 && diff < window
+// Tasks returns a copy of the tasks waiting to run, in time order.
+func (s *Scheduler) Tasks() []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]Task, len(s.tasks))
+	for i, task := range s.tasks {
+		tasks[i] = *task
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(a.Time.Compare(b.Time), cmp.Compare(a.seq, b.seq))
+	})
+	return tasks
 }
 
-func (s *Scheduler) setupDailyTasks() {
+// SetupDailyTasks replaces the scheduled tasks with those for the clock's
+// current day. Tasks already past, because trainpal started after them, are
+// not run.
+func (s *Scheduler) SetupDailyTasks() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	now := s.clock.Now()
+	s.setupDay(now, now)
+}
 
-	now := time.Now()
+// setupDay replaces the scheduled tasks with those for the day of now,
+// leaving out tasks more than lateTolerance before since. The caller must
+// hold s.mu.
+func (s *Scheduler) setupDay(now, since time.Time) {
 	s.currentDay = now.Day()
 	s.tasks = nil
 	s.arrivalPolling = make(map[TaskType]bool)
 
-	morningActive := s.cfg.MorningTrain.IsActiveToday()
-	eveningActive := s.cfg.EveningTrain.IsActiveToday()
+	var active []string
+	for i := range s.cfg.Journeys {
+		journey := &s.cfg.Journeys[i]
+		if !journey.IsActiveDay(now.Weekday()) {
+			continue
+		}
+		tasks, ok := s.scheduleJourney(journey, now)
+		if !ok {
+			continue
+		}
+		active = append(active, journey.Name)
+		for i := range tasks {
+			if since.Sub(tasks[i].Time) <= lateTolerance {
+				s.push(&tasks[i])
+			}
+		}
+	}
 
-	if !morningActive && !eveningActive {
+	if len(active) == 0 {
 		s.logger.WithField("weekday", now.Weekday().String()).Info("no trains scheduled for today")
 		return
 	}
 
-	var morningDep, eveningDep time.Time
-	var err error
+	s.logger.WithFields(logrus.Fields{
+		"weekday":         now.Weekday().String(),
+		"active_journeys": active,
+		"total_tasks":     len(s.tasks),
+	}).Info("daily tasks scheduled")
+}
 
-	if morningActive {
-		morningDep, err = s.cfg.MorningTrain.DepartureTime()
+// scheduleJourney returns the tasks for a single journey on the same date as
+// day. It returns false if the journey could not be scheduled.
+func (s *Scheduler) scheduleJourney(journey *config.TrainConfig, day time.Time) ([]Task, bool) {
+	var tasks []Task
+	route := journey.Route()
+	deps := make([]time.Time, len(route))
+	for i, leg := range route {
+		dep, err := leg.DepartureOn(day)
 		if err != nil {
-			s.logger.WithField("error", err).Error("failed to parse morning departure time")
-			morningActive = false
+			s.logger.WithFields(logrus.Fields{
+				"journey": journey.Name,
+				"error":   err,
+			}).Error("failed to parse departure time")
+			return nil, false
 		}
+		deps[i] = dep
 	}
 
-	if eveningActive {
-		eveningDep, err = s.cfg.EveningTrain.DepartureTime()
-		if err != nil {
-			s.logger.WithField("error", err).Error("failed to parse evening departure time")
-			eveningActive = false
-		}
-	}
+	checks := journey.Checks.Timings()
+	for i, leg := range route {
+		dep := deps[i]
 
-	if morningActive {
 		// Delay checks (only notify on delay)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskMorningDelayCheck, Time: morningDep.Add(-60 * time.Minute)},
-			Task{Type: TaskMorningDelayCheck, Time: morningDep.Add(-45 * time.Minute)},
-			Task{Type: TaskMorningDelayCheck, Time: morningDep.Add(-30 * time.Minute)},
-			Task{Type: TaskMorningDelayCheck, Time: morningDep.Add(-15 * time.Minute)},
-		)
+		for _, offset := range checks.Delay {
+			tasks = append(tasks, Task{Type: TaskDelayCheck, Journey: journey, Leg: leg, Time: dep.Add(offset)})
+		}
 
-		// Status updates at 60m and 30m (always notify on-time or delay)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskMorningStatusUpdate, Time: morningDep.Add(-60 * time.Minute)},
-			Task{Type: TaskMorningStatusUpdate, Time: morningDep.Add(-30 * time.Minute)},
+		// Status updates (always notify on-time or delay), first train only
+		if i == 0 {
+			for _, offset := range checks.Status {
+				tasks = append(tasks, Task{Type: TaskStatusUpdate, Journey: journey, Leg: leg, Time: dep.Add(offset)})
+			}
+		}
+
+		// Platform check (polls until departure)
+		tasks = append(tasks,
+			Task{Type: TaskPlatformCheck, Journey: journey, Leg: leg, Time: dep.Add(checks.Platform.Start), Repeating: true, Every: checks.Platform.Every},
 		)
 
-		// Departure check (starts at departure time, polls until departed)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskMorningDepartureCheck, Time: morningDep, Repeating: true},
+		// Departure check (polls until departed)
+		tasks = append(tasks,
+			Task{Type: TaskDepartureCheck, Journey: journey, Leg: leg, Time: dep.Add(checks.Departure.Start), Repeating: true, Every: checks.Departure.Every},
 		)
 
-		s.tasks = append(s.tasks,
-			Task{Type: TaskMorningArrivalCheck, Time: morningDep.Add(70 * time.Minute), Repeating: true},
+		// Connection check (starts before the feeder departs, polls until the connection departs)
+		if i > 0 {
+			tasks = append(tasks,
+				Task{Type: TaskConnectionCheck, Journey: journey, Leg: leg, Feeder: route[i-1], Time: deps[i-1].Add(checks.Connection.Start), Repeating: true, Every: checks.Connection.Every},
+			)
+		}
+	}
+
+	last := route[len(route)-1]
+	lastDep := deps[len(deps)-1]
+	tasks = append(tasks,
+		Task{Type: TaskArrivalCheck, Journey: journey, Leg: last, Time: lastDep.Add(checks.Arrival.Start), Repeating: true, Every: checks.Arrival.Every},
+	)
+
+	// Arrival delay checks while en route (polls from departure until done)
+	if len(journey.ArrivalAlerts) > 0 {
+		tasks = append(tasks,
+			Task{Type: TaskArrivalDelayCheck, Journey: journey, Leg: last, Time: lastDep.Add(checks.ArrivalDelay.Start), Repeating: true, Every: checks.ArrivalDelay.Every},
 		)
+	}
 
-		startTime := morningDep.Add(-60 * time.Minute)
-		for t := startTime; !t.After(morningDep); t = t.Add(5 * time.Minute) {
-			s.tasks = append(s.tasks, Task{Type: TaskNorthernLineCheck, Time: t})
+	dep := deps[0]
+	switch journey.Tube {
+	case config.TubePoll:
+		startTime := dep.Add(checks.TubePoll.Start)
+		for t := startTime; !t.After(dep); t = t.Add(checks.TubePoll.Every) {
+			tasks = append(tasks, Task{Type: TaskNorthernLineCheck, Time: t})
 		}
 
-		// Schedule status summary 15 mins before arrival
+		// Schedule status summary before arrival
 		arrivalTime, err := s.trainMonitor.GetExpectedArrivalTime(
 			context.Background(),
-			s.cfg.MorningTrain.From,
-			s.cfg.MorningTrain.To,
-			s.cfg.MorningTrain.Departure,
+			last.From,
+			last.To,
+			last.Departure,
 		)
 		if err != nil {
-			s.logger.WithField("error", err).Warn("failed to get arrival time, skipping status summary")
+			s.logger.WithFields(logrus.Fields{
+				"journey": journey.Name,
+				"error":   err,
+			}).Warn("failed to get arrival time, skipping status summary")
 		} else {
-			summaryTime := arrivalTime.Add(-15 * time.Minute)
-			s.tasks = append(s.tasks, Task{Type: TaskNorthernLineSummary, Time: summaryTime})
+			summaryTime := arrivalTime.Add(-checks.TubeSummary)
+			tasks = append(tasks, Task{Type: TaskNorthernLineSummary, Time: summaryTime})
 			s.logger.WithFields(logrus.Fields{
+				"journey":      journey.Name,
 				"arrival":      arrivalTime.Format("15:04"),
 				"summary_time": summaryTime.Format("15:04"),
 			}).Info("scheduled northern line status summary")
 		}
-	}
-
-	if eveningActive {
-		// Delay checks (only notify on delay)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskEveningDelayCheck, Time: eveningDep.Add(-60 * time.Minute)},
-			Task{Type: TaskEveningDelayCheck, Time: eveningDep.Add(-45 * time.Minute)},
-			Task{Type: TaskEveningDelayCheck, Time: eveningDep.Add(-30 * time.Minute)},
-			Task{Type: TaskEveningDelayCheck, Time: eveningDep.Add(-15 * time.Minute)},
-		)
-
-		// Status updates at 60m and 30m (always notify on-time or delay)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskEveningStatusUpdate, Time: eveningDep.Add(-60 * time.Minute)},
-			Task{Type: TaskEveningStatusUpdate, Time: eveningDep.Add(-30 * time.Minute)},
-		)
-
-		// Northern Line checks at 60m and 30m before evening departure
-		s.tasks = append(s.tasks,
-			Task{Type: TaskNorthernLineCheck, Time: eveningDep.Add(-60 * time.Minute)},
-			Task{Type: TaskNorthernLineCheck, Time: eveningDep.Add(-30 * time.Minute)},
-		)
-
-		// Departure check (starts at departure time, polls until departed)
-		s.tasks = append(s.tasks,
-			Task{Type: TaskEveningDepartureCheck, Time: eveningDep, Repeating: true},
-		)
 
-		s.tasks = append(s.tasks,
-			Task{Type: TaskEveningArrivalCheck, Time: eveningDep.Add(70 * time.Minute), Repeating: true},
-		)
+	case config.TubeCheck:
+		// Northern Line checks before departure
+		for _, offset := range checks.TubeCheck {
+			tasks = append(tasks, Task{Type: TaskNorthernLineCheck, Time: dep.Add(offset)})
+		}
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"weekday":        now.Weekday().String(),
-		"morning_active": morningActive,
-		"evening_active": eveningActive,
-		"total_tasks":    len(s.tasks),
-	}).Info("daily tasks scheduled")
+	return tasks, true
 }
 
 func (s *Scheduler) executeTask(ctx context.Context, task *Task) {
-	s.logger.WithFields(logrus.Fields{
+	fields := logrus.Fields{
 		"type":           task.Type,
 		"scheduled_time": task.Time.Format("15:04"),
-	}).Debug("executing task")
+	}
+	if task.Journey != nil {
+		fields["journey"] = task.Journey.Name
+		fields["leg"] = task.Leg.From + " -> " + task.Leg.To
+	}
+	s.logger.WithFields(fields).Debug("executing task")
 
 	var err error
 
 	switch task.Type {
-	case TaskMorningDelayCheck:
-		err = s.trainMonitor.CheckDelay(ctx,
-			s.cfg.MorningTrain.From,
-			s.cfg.MorningTrain.To,
-			s.cfg.MorningTrain.Departure)
-
-	case TaskEveningDelayCheck:
-		err = s.trainMonitor.CheckDelay(ctx,
-			s.cfg.EveningTrain.From,
-			s.cfg.EveningTrain.To,
-			s.cfg.EveningTrain.Departure)
-
-	case TaskMorningArrivalCheck:
-		arrived, checkErr := s.trainMonitor.CheckArrival(ctx,
-			s.cfg.MorningTrain.From,
-			s.cfg.MorningTrain.To,
-			s.cfg.MorningTrain.Departure)
+	case TaskDelayCheck:
+		err = s.trainMonitor.CheckDelay(ctx, task.Journey, task.Leg)
+
+	case TaskStatusUpdate:
+		err = s.trainMonitor.CheckStatus(ctx, task.Journey, task.Leg)
+
+	case TaskDepartureCheck:
+		departed, checkErr := s.trainMonitor.CheckDeparture(ctx, task.Journey, task.Leg)
 		err = checkErr
-		if arrived {
+		if departed {
 			task.Repeating = false
 		} else {
-			task.Time = task.Time.Add(5 * time.Minute)
+			task.Time = task.Time.Add(task.Every)
 		}
 
-	case TaskEveningArrivalCheck:
-		arrived, checkErr := s.trainMonitor.CheckArrival(ctx,
-			s.cfg.EveningTrain.From,
-			s.cfg.EveningTrain.To,
-			s.cfg.EveningTrain.Departure)
+	case TaskArrivalCheck:
+		arrived, checkErr := s.trainMonitor.CheckArrival(ctx, task.Journey, task.Leg)
 		err = checkErr
 		if arrived {
 			task.Repeating = false
 		} else {
-			task.Time = task.Time.Add(5 * time.Minute)
+			task.Time = task.Time.Add(task.Every)
 		}
 
-	case TaskNorthernLineCheck:
-		err = s.tubeMonitor.CheckStatus(ctx)
-
-	case TaskNorthernLineSummary:
-		err = s.tubeMonitor.SendStatusSummary(ctx)
-
-	case TaskMorningStatusUpdate:
-		err = s.trainMonitor.CheckStatus(ctx,
-			s.cfg.MorningTrain.From,
-			s.cfg.MorningTrain.To,
-			s.cfg.MorningTrain.Departure)
-
-	case TaskEveningStatusUpdate:
-		err = s.trainMonitor.CheckStatus(ctx,
-			s.cfg.EveningTrain.From,
-			s.cfg.EveningTrain.To,
-			s.cfg.EveningTrain.Departure)
-
-	case TaskMorningDepartureCheck:
-		departed, checkErr := s.trainMonitor.CheckDeparture(ctx,
-			s.cfg.MorningTrain.From,
-			s.cfg.MorningTrain.To,
-			s.cfg.MorningTrain.Departure)
+	case TaskConnectionCheck:
+		done, checkErr := s.trainMonitor.CheckConnection(ctx, task.Journey, task.Feeder, task.Leg)
 		err = checkErr
-		if departed {
+		task.Time = task.Time.Add(task.Every)
+		if done {
 			task.Repeating = false
-		} else {
-			task.Time = task.Time.Add(2 * time.Minute)
+		} else if checkErr != nil {
+			// Without an answer from the monitor, give up at the booked departure.
+			if dep, depErr := task.Leg.DepartureOn(task.Time); depErr != nil || task.Time.After(dep) {
+				task.Repeating = false
+			}
 		}
 
-	case TaskEveningDepartureCheck:
-		departed, checkErr := s.trainMonitor.CheckDeparture(ctx,
-			s.cfg.EveningTrain.From,
-			s.cfg.EveningTrain.To,
-			s.cfg.EveningTrain.Departure)
+	case TaskPlatformCheck:
+		err = s.trainMonitor.CheckPlatform(ctx, task.Journey, task.Leg)
+		task.Time = task.Time.Add(task.Every)
+		if dep, depErr := task.Leg.DepartureOn(task.Time); depErr != nil || task.Time.After(dep) {
+			task.Repeating = false
+		}
+
+	case TaskArrivalDelayCheck:
+		done, checkErr := s.trainMonitor.CheckArrivalDelay(ctx, task.Journey, task.Leg)
 		err = checkErr
-		if departed {
+		if done {
 			task.Repeating = false
 		} else {
-			task.Time = task.Time.Add(2 * time.Minute)
+			task.Time = task.Time.Add(task.Every)
 		}
-	}
 
-	if err != nil {
-		s.logger.WithFields(logrus.Fields{
-			"type":  task.Type,
-			"error": err,
-		}).Error("task execution failed")
+	case TaskNorthernLineCheck:
+		err = s.tubeMonitor.CheckStatus(ctx)
+
+	case TaskNorthernLineSummary:
+		err = s.tubeMonitor.SendStatusSummary(ctx)
 	}
 
-	if !task.Repeating {
-		task.Executed = true
+	if err != nil {
+		fields["error"] = err
+		s.logger.WithFields(fields).Error("task execution failed")
 	}
 }
@@ -0,0 +1,352 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/api/rtt/rtttest"
+	"github.com/danpilch/trainpal/internal/api/tfl"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/monitor"
+)
+
+// monday is the start of the first day the tests run through.
+var monday = time.Date(2025, 1, 6, 0, 0, 0, 0, time.Local)
+
+// at returns the time of day hhmm on day.
+func at(day time.Time, hhmm string) time.Time {
+	t, err := time.Parse("1504", hhmm)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+}
+
+// taskRecorder is a log hook recording when each task ran, by the clock.
+type taskRecorder struct {
+	clock *clock.Fake
+
+	mu   sync.Mutex
+	runs []taskRun
+}
+
+type taskRun struct {
+	Type      string
+	Journey   string
+	Scheduled string // HH:MM
+	At        time.Time
+}
+
+func (r *taskRecorder) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (r *taskRecorder) Fire(e *logrus.Entry) error {
+	if e.Message != "executing task" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, taskRun{
+		Type:      fmt.Sprint(e.Data["type"]),
+		Journey:   fmt.Sprint(e.Data["journey"]),
+		Scheduled: fmt.Sprint(e.Data["scheduled_time"]),
+		At:        r.clock.Now(),
+	})
+	return nil
+}
+
+// times returns the times of day tasks of a type ran on day.
+func (r *taskRecorder) times(typ TaskType, day time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var times []string
+	for _, run := range r.runs {
+		y, m, d := run.At.Date()
+		if run.Type == typ.String() && y == day.Year() && m == day.Month() && d == day.Day() {
+			times = append(times, run.At.Format("1504"))
+		}
+	}
+	return times
+}
+
+// ranAt returns the tasks that ran at t, as "<journey> <type> <scheduled>" in
+// the order they ran.
+func (r *taskRecorder) ranAt(t time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ran []string
+	for _, run := range r.runs {
+		if run.At.Equal(t) {
+			ran = append(ran, run.Journey+" "+run.Type+" "+run.Scheduled)
+		}
+	}
+	return ran
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(events.Event, *events.Notified) error {
+	return nil
+}
+
+// newTestScheduler creates a scheduler for cfg checking trains on srv, on a
+// fake clock set to the start of monday.
+func newTestScheduler(t *testing.T, cfg *config.Config, srv *rtttest.Server) (*Scheduler, *clock.Fake, *taskRecorder) {
+	t.Helper()
+	sim := clock.NewFake(monday)
+	srv.SetNow(sim.Now)
+
+	recorder := &taskRecorder{clock: sim}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.DebugLevel)
+	logger.AddHook(recorder)
+
+	trainMonitor := monitor.NewTrainMonitor(rtt.NewClient(srv.URL, "", ""), nopPublisher{}, nil, sim, logger)
+	tubeMonitor := monitor.NewTubeMonitor(tfl.NewClient(), nopPublisher{}, nil, sim, logger)
+	return NewScheduler(cfg, trainMonitor, tubeMonitor, sim, logger), sim, recorder
+}
+
+func TestSchedulerRunsDays(t *testing.T) {
+	srv := rtttest.NewServer()
+	defer srv.Close()
+
+	tuesday := monday.AddDate(0, 0, 1)
+	wednesday := monday.AddDate(0, 0, 2)
+	for _, day := range []time.Time{monday, tuesday, wednesday} {
+		srv.Add(rtttest.Service{
+			UID: "W12345", From: "WIN", To: "WAT",
+			Departure: at(day, "0800"), Arrival: at(day, "0900"),
+			Timeline: []rtttest.Update{
+				rtttest.Delayed(at(day, "0720"), 5),
+				rtttest.Departed(at(day, "0805"), 5),
+				rtttest.Arrived(at(day, "0912"), 12),
+			},
+		})
+	}
+
+	cfg := &config.Config{Journeys: []config.TrainConfig{{
+		Name: "morning", From: "WIN", To: "WAT", Departure: "0800",
+		Days: []string{"monday", "tuesday"},
+	}}}
+	sched, sim, recorder := newTestScheduler(t, cfg, srv)
+
+	sched.Start(context.Background())
+	sim.Set(at(wednesday, "2359"))
+	sched.Stop()
+
+	for _, day := range []time.Time{monday, tuesday} {
+		t.Run(day.Weekday().String(), func(t *testing.T) {
+			tests := []struct {
+				typ  TaskType
+				want []string
+			}{
+				{TaskDelayCheck, []string{"0700", "0715", "0730", "0745"}},
+				{TaskStatusUpdate, []string{"0700", "0730"}},
+				// Polls every 2 minutes until the departure
+				{TaskPlatformCheck, []string{"0747", "0749", "0751", "0753", "0755", "0757", "0759"}},
+				// Polls every 2 minutes until the train is seen departed at 0806
+				{TaskDepartureCheck, []string{"0800", "0802", "0804", "0806"}},
+				// Polls every 5 minutes from 70 minutes after departure until
+				// the train is seen arrived at 0915
+				{TaskArrivalCheck, []string{"0910", "0915"}},
+			}
+			for _, tt := range tests {
+				if got := recorder.times(tt.typ, day); !slices.Equal(got, tt.want) {
+					t.Errorf("%s ran at %v, want %v", tt.typ, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// The journey does not run on Wednesdays.
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for _, run := range recorder.runs {
+		if run.At.Weekday() == time.Wednesday {
+			t.Errorf("%s ran on Wednesday at %s", run.Type, run.At.Format("1504"))
+		}
+	}
+}
+
+func TestSchedulerSkipsTasksPastAtStart(t *testing.T) {
+	srv := rtttest.NewServer()
+	defer srv.Close()
+	srv.Add(rtttest.Service{
+		UID: "W12345", From: "WIN", To: "WAT",
+		Departure: at(monday, "0800"), Arrival: at(monday, "0900"),
+	})
+
+	cfg := &config.Config{Journeys: []config.TrainConfig{{
+		Name: "morning", From: "WIN", To: "WAT", Departure: "0800",
+		Checks: config.ChecksConfig{Status: []int{}},
+	}}}
+	sched, sim, recorder := newTestScheduler(t, cfg, srv)
+
+	// Starting at 0720, the 0700 check is past, and the 0715 check was due
+	// over lateTolerance ago.
+	sim.Set(at(monday, "0720"))
+	sched.Start(context.Background())
+	sim.Set(at(monday, "0750"))
+	sched.Stop()
+
+	want := []string{"0730", "0745"}
+	if got := recorder.times(TaskDelayCheck, monday); !slices.Equal(got, want) {
+		t.Errorf("delay checks ran at %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	tuesday := monday.AddDate(0, 0, 1)
+	cfg := &config.Config{Journeys: []config.TrainConfig{
+		{Name: "evening", From: "WAT", To: "WIN", Departure: "2330", Days: []string{"monday"}},
+		{Name: "morning", From: "WIN", To: "WAT", Departure: "0800", Days: []string{"tuesday"}},
+	}}
+
+	tests := []struct {
+		name    string
+		policy  string
+		suspend time.Time // when the machine is suspended, until 0750 on Tuesday
+		want    []string  // the tasks run on resuming, in time order
+	}{
+		{
+			name:    "latest",
+			policy:  config.CatchUpLatest,
+			suspend: at(tuesday, "0650"),
+			want: []string{
+				"morning status_update 07:30",
+				"morning delay_check 07:45",
+				"morning platform_check 07:47",
+			},
+		},
+		{
+			name:    "all",
+			policy:  config.CatchUpAll,
+			suspend: at(tuesday, "0650"),
+			want: []string{
+				"morning delay_check 07:00",
+				"morning status_update 07:00",
+				"morning delay_check 07:15",
+				"morning delay_check 07:30",
+				"morning status_update 07:30",
+				"morning delay_check 07:45",
+				"morning platform_check 07:47",
+			},
+		},
+		{
+			name:    "skip",
+			policy:  config.CatchUpSkip,
+			suspend: at(tuesday, "0650"),
+			want:    []string{"morning platform_check 07:47"},
+		},
+		{
+			// The previous evening's checks were missed too, but not its polls,
+			// which stop with the day.
+			name:    "latest over midnight",
+			policy:  config.CatchUpLatest,
+			suspend: at(monday, "2220"),
+			want: []string{
+				"evening status_update 23:00",
+				"evening delay_check 23:15",
+				"morning status_update 07:30",
+				"morning delay_check 07:45",
+				"morning platform_check 07:47",
+			},
+		},
+		{
+			name:    "all over midnight",
+			policy:  config.CatchUpAll,
+			suspend: at(monday, "2220"),
+			want: []string{
+				"evening delay_check 22:30",
+				"evening status_update 22:30",
+				"evening delay_check 22:45",
+				"evening delay_check 23:00",
+				"evening status_update 23:00",
+				"evening delay_check 23:15",
+				"morning delay_check 07:00",
+				"morning status_update 07:00",
+				"morning delay_check 07:15",
+				"morning delay_check 07:30",
+				"morning status_update 07:30",
+				"morning delay_check 07:45",
+				"morning platform_check 07:47",
+			},
+		},
+		{
+			name:    "skip over midnight",
+			policy:  config.CatchUpSkip,
+			suspend: at(monday, "2220"),
+			want:    []string{"morning platform_check 07:47"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := rtttest.NewServer()
+			defer srv.Close()
+
+			cfg := *cfg
+			cfg.CatchUp = tt.policy
+			sched, sim, recorder := newTestScheduler(t, &cfg, srv)
+			sched.Start(context.Background())
+
+			sim.Set(tt.suspend)
+			sim.Suspend(at(tuesday, "0750").Sub(tt.suspend))
+			// The scheduler sleeps at most maxSleep, so it wakes by 0755.
+			sim.Set(at(tuesday, "0755"))
+			sched.Stop()
+
+			var resumed time.Time
+			recorder.mu.Lock()
+			for _, run := range recorder.runs {
+				if !run.At.Before(at(tuesday, "0750")) {
+					resumed = run.At
+					break
+				}
+			}
+			recorder.mu.Unlock()
+			if resumed.IsZero() {
+				t.Fatal("no tasks ran after resuming")
+			}
+			if got := recorder.ranAt(resumed); !slices.Equal(got, tt.want) {
+				t.Errorf("ran on resuming:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSchedulerAddWakesEarly(t *testing.T) {
+	srv := rtttest.NewServer()
+	defer srv.Close()
+
+	journey := config.TrainConfig{Name: "morning", From: "WIN", To: "WAT", Departure: "0800"}
+	cfg := &config.Config{}
+	sched, sim, recorder := newTestScheduler(t, cfg, srv)
+	sched.Start(context.Background())
+
+	// The scheduler has nothing to do, so it is asleep until 0005.
+	due := monday.Add(90 * time.Second)
+	sched.Add(Task{Type: TaskDelayCheck, Journey: &journey, Leg: journey.Route()[0], Time: due})
+	sim.Set(monday.Add(3 * time.Minute))
+	sched.Stop()
+
+	if got, want := recorder.ranAt(due), []string{"morning delay_check 00:01"}; !slices.Equal(got, want) {
+		t.Errorf("ran at %s: %v, want %v", due.Format(time.TimeOnly), got, want)
+	}
+	if tasks := sched.Tasks(); len(tasks) != 0 {
+		t.Errorf("Tasks() = %+v, want none left", tasks)
+	}
+}
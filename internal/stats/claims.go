@@ -0,0 +1,32 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// WriteClaimsTable writes Delay Repay claims as an aligned text table.
+func WriteClaimsTable(w io.Writer, claims []history.DelayRepayClaim) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tJOURNEY\tFROM\tTO\tDEPARTURE\tBOOKED ARRIVAL\tACTUAL ARRIVAL\tDELAY\tBAND\tSERVICE")
+	for _, c := range claims {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%dm\t%dm\t%s\n",
+			c.RunDate, c.Journey, c.From, c.To, c.BookedDeparture,
+			c.BookedArrival, c.ActualArrival, c.DelayMinutes, c.Band, c.ServiceUID)
+	}
+	return tw.Flush()
+}
+
+// WriteClaimsJSON writes Delay Repay claims as indented JSON.
+func WriteClaimsJSON(w io.Writer, claims []history.DelayRepayClaim) error {
+	if claims == nil {
+		claims = []history.DelayRepayClaim{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(claims)
+}
@@ -0,0 +1,259 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danpilch/trainpal/internal/history"
+)
+
+// Journey is the punctuality summary for one journey.
+type Journey struct {
+	Journey       string         `json:"journey"`
+	Days          int            `json:"days"`
+	OnTime        int            `json:"on_time"`
+	OnTimePercent float64        `json:"on_time_percent"`
+	AverageDelay  float64        `json:"average_delay"`
+	P90Delay      int            `json:"p90_delay"`
+	Cancellations int            `json:"cancellations"`
+	CancelReasons []ReasonCount  `json:"cancel_reasons"`
+	WorstWeekdays []WeekdayDelay `json:"worst_weekdays"`
+}
+
+// accumulator collects the raw figures for a journey before summarising.
+type accumulator struct {
+	Journey
+	delays        []int
+	weekdayDelays map[time.Weekday][]int
+	cancelReasons map[string]int
+}
+
+// ReasonCount is the number of cancellations given a reason.
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// WeekdayDelay is the average delay of a journey on a weekday.
+type WeekdayDelay struct {
+	Weekday      string  `json:"weekday"`
+	Days         int     `json:"days"`
+	AverageDelay float64 `json:"average_delay"`
+}
+
+// maxReasons and maxWeekdays limit the reasons and weekdays reported.
+const (
+	maxReasons  = 3
+	maxWeekdays = 3
+)
+
+// day is what was recorded of a journey on one day, over all its services.
+type day struct {
+	journey   string
+	date      string
+	arrived   bool
+	delay     int
+	cancelled []string // the reasons for each cancelled service
+}
+
+// Compute summarises the recorded services per journey, taking each day's
+// services together. A day is on time if the journey arrived at its
+// destination less than onTimeWithin minutes after the booked arrival, end to
+// end, including any later train taken after a cancellation or missed
+// connection. A day with a cancelled service counts as a cancellation; only
+// days that arrived contribute to the delay figures, and days that neither
+// arrived nor had a cancellation are left out.
+func Compute(services []history.Service, onTimeWithin int) []Journey {
+	byJourney := make(map[string]*accumulator)
+	for _, d := range days(services) {
+		if !d.arrived && len(d.cancelled) == 0 {
+			continue
+		}
+		j := byJourney[d.journey]
+		if j == nil {
+			j = &accumulator{
+				Journey:       Journey{Journey: d.journey},
+				weekdayDelays: make(map[time.Weekday][]int),
+				cancelReasons: make(map[string]int),
+			}
+			byJourney[d.journey] = j
+		}
+
+		j.Days++
+		if len(d.cancelled) > 0 {
+			j.Cancellations++
+			for _, reason := range d.cancelled {
+				j.cancelReasons[reason]++
+			}
+		}
+		if !d.arrived {
+			continue
+		}
+
+		j.delays = append(j.delays, d.delay)
+		if d.delay < onTimeWithin {
+			j.OnTime++
+		}
+		if date, err := time.Parse(history.DateFormat, d.date); err == nil {
+			j.weekdayDelays[date.Weekday()] = append(j.weekdayDelays[date.Weekday()], d.delay)
+		}
+	}
+
+	result := make([]Journey, 0, len(byJourney))
+	for _, j := range byJourney {
+		result = append(result, j.summarise())
+	}
+	sort.Slice(result, func(i, k int) bool {
+		return result[i].Journey < result[k].Journey
+	})
+	return result
+}
+
+// days groups the services by journey and date. A day arrived if a service
+// was seen arriving at the end of the journey, with the largest end to end
+// delay of those arrivals.
+func days(services []history.Service) []*day {
+	type key struct{ journey, date string }
+	byDay := make(map[key]*day)
+	var result []*day
+	for _, svc := range services {
+		if svc.Journey == "" {
+			continue
+		}
+		k := key{svc.Journey, svc.Date}
+		d := byDay[k]
+		if d == nil {
+			d = &day{journey: svc.Journey, date: svc.Date}
+			byDay[k] = d
+			result = append(result, d)
+		}
+
+		if svc.Cancelled {
+			reason := svc.CancelReason
+			if reason == "" {
+				reason = "No reason provided"
+			}
+			d.cancelled = append(d.cancelled, reason)
+		}
+		if svc.Arrived && svc.ActualArrival != "" {
+			// Records from before journey delays were kept only have the
+			// service's own arrival delay.
+			delay := max(svc.ArrivalDelay, svc.JourneyDelay)
+			if !d.arrived || delay > d.delay {
+				d.delay = delay
+			}
+			d.arrived = true
+		}
+	}
+	return result
+}
+
+func (j *accumulator) summarise() Journey {
+	j.CancelReasons = []ReasonCount{}
+	j.WorstWeekdays = []WeekdayDelay{}
+	if j.Days > 0 {
+		j.OnTimePercent = round1(float64(j.OnTime) / float64(j.Days) * 100)
+	}
+	j.AverageDelay = round1(average(j.delays))
+	j.P90Delay = percentile(j.delays, 90)
+
+	for reason, count := range j.cancelReasons {
+		j.CancelReasons = append(j.CancelReasons, ReasonCount{Reason: reason, Count: count})
+	}
+	sort.Slice(j.CancelReasons, func(a, b int) bool {
+		if j.CancelReasons[a].Count != j.CancelReasons[b].Count {
+			return j.CancelReasons[a].Count > j.CancelReasons[b].Count
+		}
+		return j.CancelReasons[a].Reason < j.CancelReasons[b].Reason
+	})
+	if len(j.CancelReasons) > maxReasons {
+		j.CancelReasons = j.CancelReasons[:maxReasons]
+	}
+
+	for weekday, delays := range j.weekdayDelays {
+		j.WorstWeekdays = append(j.WorstWeekdays, WeekdayDelay{
+			Weekday:      weekday.String(),
+			Days:         len(delays),
+			AverageDelay: round1(average(delays)),
+		})
+	}
+	sort.Slice(j.WorstWeekdays, func(a, b int) bool {
+		if j.WorstWeekdays[a].AverageDelay != j.WorstWeekdays[b].AverageDelay {
+			return j.WorstWeekdays[a].AverageDelay > j.WorstWeekdays[b].AverageDelay
+		}
+		return j.WorstWeekdays[a].Weekday < j.WorstWeekdays[b].Weekday
+	})
+	if len(j.WorstWeekdays) > maxWeekdays {
+		j.WorstWeekdays = j.WorstWeekdays[:maxWeekdays]
+	}
+	return j.Journey
+}
+
+// WriteTable writes the summaries as an aligned text table.
+func WriteTable(w io.Writer, journeys []Journey) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOURNEY\tDAYS\tON TIME\tAVG DELAY\tP90 DELAY\tCANCELLED\tCANCEL REASONS\tWORST WEEKDAYS")
+	for _, j := range journeys {
+		var reasons []string
+		for _, r := range j.CancelReasons {
+			reasons = append(reasons, fmt.Sprintf("%s (%d)", r.Reason, r.Count))
+		}
+		var weekdays []string
+		for _, d := range j.WorstWeekdays {
+			weekdays = append(weekdays, fmt.Sprintf("%s %.1fm", d.Weekday, d.AverageDelay))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%.1fm\t%dm\t%d\t%s\t%s\n",
+			j.Journey, j.Days, j.OnTimePercent, j.AverageDelay, j.P90Delay,
+			j.Cancellations, orDash(strings.Join(reasons, ", ")), orDash(strings.Join(weekdays, ", ")))
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the summaries as indented JSON.
+func WriteJSON(w io.Writer, journeys []Journey) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(journeys)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func average(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum int
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile of values.
+func percentile(values []int, p int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
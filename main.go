@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,20 +10,42 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/sirupsen/logrus"
 
+	"github.com/danpilch/trainpal/internal/api/fixture"
 	"github.com/danpilch/trainpal/internal/api/rtt"
 	"github.com/danpilch/trainpal/internal/api/tfl"
+	"github.com/danpilch/trainpal/internal/bot"
+	"github.com/danpilch/trainpal/internal/clock"
 	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
 	"github.com/danpilch/trainpal/internal/monitor"
+	"github.com/danpilch/trainpal/internal/notifiers"
 	"github.com/danpilch/trainpal/internal/notify"
+	"github.com/danpilch/trainpal/internal/outbox"
 	"github.com/danpilch/trainpal/internal/scheduler"
 )
 
+// Globals are the flags shared by every command.
+type Globals struct {
+	Config  string `help:"Path to config file" default:"config.yaml" type:"path"`
+	History string `help:"Path to journey history file" default:"history.jsonl" type:"path"`
+	Mutes   string `help:"Path to runtime mutes file" default:"mutes.json" type:"path"`
+	Outbox  string `help:"Path to undelivered notifications file" default:"outbox.json" type:"path"`
+	State   string `help:"Path to notifier state file, such as pending email digests" default:"notifiers.json" type:"path"`
+}
+
 var CLI struct {
-	Config string `help:"Path to config file" default:"config.yaml" type:"path"`
+	Globals
+
+	Run      RunCmd      `cmd:"" default:"withargs" help:"Monitor journeys and send notifications (default)"`
+	Stats    StatsCmd    `cmd:"" help:"Report punctuality statistics from the journey history"`
+	Mute     MuteCmd     `cmd:"" help:"Mute normal priority notifications while trainpal is running"`
+	Replay   ReplayCmd   `cmd:"" help:"Replay a recorded day of RTT and TfL responses and print the alerts"`
+	Simulate SimulateCmd `cmd:"" help:"Print the checks scheduled for a day, optionally running them against recorded responses"`
 }
 
 func main() {
-	kong.Parse(&CLI)
+	ctx := kong.Parse(&CLI)
 
 	// Setup structured logging with logfmt
 	logger := logrus.New()
@@ -33,36 +56,81 @@ func main() {
 		FullTimestamp: true,
 	})
 
+	ctx.FatalIfErrorf(ctx.Run(&CLI.Globals, logger))
+}
+
+type RunCmd struct {
+	Record string `help:"Record RTT and TfL responses into this directory, to replay later" type:"path"`
+}
+
+func (r *RunCmd) Run(globals *Globals, logger *logrus.Logger) error {
 	// Load configuration
-	cfg, err := config.Load(CLI.Config)
+	cfg, err := config.Load(globals.Config)
 	if err != nil {
 		logger.WithField("error", err).Fatal("failed to load config")
 	}
 
 	// Get credentials from environment
-	pushoverToken := os.Getenv("PUSHOVER_TOKEN")
-	pushoverUser := os.Getenv("PUSHOVER_USER")
-	if pushoverToken == "" || pushoverUser == "" {
-		logger.Fatal("PUSHOVER_TOKEN and PUSHOVER_USER environment variables are required")
-	}
-
 	rttUsername := os.Getenv("RTT_USERNAME")
 	rttPassword := os.Getenv("RTT_PASSWORD")
 	if rttUsername == "" || rttPassword == "" {
 		logger.Fatal("RTT_USERNAME and RTT_PASSWORD environment variables are required")
 	}
 
+	// Open journey history
+	store, err := history.Open(globals.History, logger)
+	if err != nil {
+		logger.WithField("error", err).Fatal("failed to open journey history")
+	}
+	defer store.Close()
+
 	// Initialize clients
-	rttClient := rtt.NewClient(rttUsername, rttPassword)
+	rttClient := rtt.NewClient(os.Getenv("RTT_URL"), rttUsername, rttPassword)
 	tflClient := tfl.NewClient()
-	notifier := notify.NewNotifier(pushoverToken, pushoverUser, logger)
+	if r.Record != "" {
+		recorder := fixture.NewRecorder(r.Record, http.DefaultTransport, clock.Real{}, logger)
+		rttClient.SetTransport(recorder)
+		tflClient.SetTransport(recorder)
+		logger.WithField("dir", r.Record).Info("recording api responses")
+	}
+	opts := notifiers.Options{
+		Mutes:   globals.Mutes,
+		State:   notify.NewStateFile(globals.State),
+		History: store,
+		Clock:   clock.Real{},
+		Logger:  logger,
+	}
+	sender, err := notifiers.NewRecipients(cfg, opts)
+	if err != nil {
+		logger.WithField("error", err).Fatal("failed to set up notifiers")
+	}
+	defer notify.Close(sender)
+
+	templates, err := notifiers.NewTemplates(cfg.Templates)
+	if err != nil {
+		logger.WithField("error", err).Fatal("failed to parse notification templates")
+	}
+
+	// Count monitor events and record them in the history, and deliver them
+	// as notifications through an outbox that retries failed deliveries
+	bus := events.NewBus()
+	counter := events.NewCounter()
+	bus.Subscribe(counter.Handle)
+	bus.Subscribe(store.Handle)
+	notifier := notify.NewNotifier(sender, templates, logger)
+	queue, err := outbox.Open(globals.Outbox, notifier, bus.Publish, store, clock.Real{}, logger)
+	if err != nil {
+		logger.WithField("error", err).Fatal("failed to open outbox")
+	}
 
 	// Initialize monitors
-	trainMonitor := monitor.NewTrainMonitor(rttClient, notifier, logger)
-	tubeMonitor := monitor.NewTubeMonitor(tflClient, notifier, logger)
+	trainMonitor := monitor.NewTrainMonitor(rttClient, queue, store, clock.Real{}, logger)
+	tubeMonitor := monitor.NewTubeMonitor(tflClient, queue, store, clock.Real{}, logger)
+	trainMonitor.RestoreNotificationState()
+	tubeMonitor.RestoreNotificationState()
 
 	// Initialize scheduler
-	sched := scheduler.NewScheduler(cfg, trainMonitor, tubeMonitor, logger)
+	sched := scheduler.NewScheduler(cfg, trainMonitor, tubeMonitor, clock.Real{}, logger)
 
 	// Setup signal handling for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -76,17 +144,53 @@ func main() {
 	}()
 
 	// Start scheduler
-	logger.WithFields(logrus.Fields{
-		"morning_train": cfg.MorningTrain.From + " -> " + cfg.MorningTrain.To + " @ " + cfg.MorningTrain.Departure,
-		"evening_train": cfg.EveningTrain.From + " -> " + cfg.EveningTrain.To + " @ " + cfg.EveningTrain.Departure,
-	}).Info("starting trainpal")
+	journeys := make(logrus.Fields, len(cfg.Journeys))
+	for _, j := range cfg.Journeys {
+		journeys[j.Name] = j.From + " -> " + j.To + " @ " + j.Departure
+	}
+	logger.WithFields(journeys).Info("starting trainpal")
 
+	go queue.Run(ctx)
 	sched.Start(ctx)
 
+	// Answer chat commands
+	chats, err := notifiers.Chats(cfg, opts)
+	if err != nil {
+		logger.WithField("error", err).Fatal("failed to set up telegram bots")
+	}
+	// Each bot token is polled once, routing commands to the bot for the
+	// chat they came from.
+	pollers := make(map[string]*notify.Telegram)
+	handlers := make(map[string]map[string]notify.CommandHandler)
+	for _, chat := range chats {
+		if pollers[chat.Token] == nil {
+			pollers[chat.Token] = chat.Telegram
+			handlers[chat.Token] = make(map[string]notify.CommandHandler)
+		}
+		chatID := chat.Telegram.ChatID()
+		if _, ok := handlers[chat.Token][chatID]; ok {
+			logger.WithFields(logrus.Fields{
+				"notifier": chat.Notifier,
+				"chat":     chatID,
+			}).Warn("chat already has a bot, ignoring its commands for this notifier")
+			continue
+		}
+		b := bot.New(cfg, chat.Journeys, trainMonitor, tubeMonitor, globals.Mutes, chat.Notifier, logger)
+		handlers[chat.Token][chatID] = b.Handle
+	}
+	for token, telegram := range pollers {
+		go telegram.Poll(ctx, handlers[token])
+	}
+
 	// Wait for context cancellation
 	<-ctx.Done()
 
 	// Stop scheduler gracefully
 	sched.Stop()
-	logger.Info("trainpal stopped")
+	counts := logrus.Fields{"undelivered": queue.Pending()}
+	for kind, n := range counter.Counts() {
+		counts[kind] = n
+	}
+	logger.WithFields(counts).Info("trainpal stopped")
+	return nil
 }
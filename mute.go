@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danpilch/trainpal/internal/notify"
+)
+
+type MuteCmd struct {
+	Until     string `arg:"" optional:"" help:"How long to mute for, e.g. 2h, or the time to mute until, e.g. 0730"`
+	Recipient string `help:"Only mute this recipient"`
+	Notifier  string `help:"Only mute this notifier, by name; with --recipient, one of theirs"`
+	Clear     bool   `help:"Remove the mute instead"`
+}
+
+func (c *MuteCmd) Run(globals *Globals) error {
+	mutes, err := notify.LoadMutes(globals.Mutes)
+	if err != nil {
+		return err
+	}
+
+	var until time.Time
+	if !c.Clear {
+		if c.Until == "" {
+			return fmt.Errorf("a duration or time to mute until is required, or --clear")
+		}
+		until, err = muteUntil(c.Until, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	target := "all notifiers"
+	switch {
+	case c.Notifier != "":
+		target = notify.NotifierKey(c.Recipient, c.Notifier)
+		if mutes.Notifiers == nil {
+			mutes.Notifiers = make(map[string]time.Time)
+		}
+		mutes.Notifiers[target] = until
+	case c.Recipient != "":
+		target = c.Recipient
+		if mutes.Recipients == nil {
+			mutes.Recipients = make(map[string]time.Time)
+		}
+		mutes.Recipients[c.Recipient] = until
+	default:
+		mutes.All = until
+	}
+
+	if err := notify.SaveMutes(globals.Mutes, mutes); err != nil {
+		return err
+	}
+
+	if c.Clear {
+		fmt.Printf("Unmuted %s\n", target)
+	} else {
+		fmt.Printf("Muted %s until %s\n", target, until.Format("Mon 2 Jan 15:04"))
+	}
+	return nil
+}
+
+// muteUntil parses a duration such as "2h", or a time of day such as "0730"
+// which is taken as tomorrow if it has already passed today.
+func muteUntil(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("mute duration must be positive")
+		}
+		return now.Add(d), nil
+	}
+	t, err := time.Parse("1504", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid mute %q: want a duration such as 2h or a time such as 0730", s)
+	}
+	until := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !until.After(now) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/fixture"
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/api/tfl"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/events"
+	"github.com/danpilch/trainpal/internal/history"
+	"github.com/danpilch/trainpal/internal/monitor"
+	"github.com/danpilch/trainpal/internal/notifiers"
+	"github.com/danpilch/trainpal/internal/notify"
+	"github.com/danpilch/trainpal/internal/outbox"
+	"github.com/danpilch/trainpal/internal/scheduler"
+)
+
+type ReplayCmd struct {
+	Fixtures string `arg:"" help:"Directory of responses recorded with run --record" type:"existingdir"`
+	Date     string `help:"Day to replay (YYYY-MM-DD), default the day of the earliest recording"`
+	Verbose  bool   `help:"Show the scheduler and monitor logs, not just warnings"`
+}
+
+// Run replays a day on a simulated clock, with the scheduler
+// and monitors reading the recorded responses and the alerts printed instead
+// of sent.
+func (c *ReplayCmd) Run(globals *Globals, logger *logrus.Logger) error {
+	cfg, err := config.Load(globals.Config)
+	if err != nil {
+		return err
+	}
+	templates, err := notifiers.NewTemplates(cfg.Templates)
+	if err != nil {
+		return err
+	}
+
+	sim := clock.NewFake(time.Time{})
+	replayer, err := fixture.Load(c.Fixtures, sim)
+	if err != nil {
+		return err
+	}
+	day, ok := replayer.First()
+	if !ok {
+		return fmt.Errorf("no recordings in %s", c.Fixtures)
+	}
+	if c.Date != "" {
+		day, err = time.ParseInLocation(history.DateFormat, c.Date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --date: %w", err)
+		}
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+
+	logger.AddHook(clockHook{sim})
+	if !c.Verbose {
+		logger.SetLevel(logrus.WarnLevel)
+	}
+
+	rttClient := rtt.NewClient(os.Getenv("RTT_URL"), "", "")
+	rttClient.SetTransport(replayer)
+	tflClient := tfl.NewClient()
+	tflClient.SetTransport(replayer)
+
+	sim.Set(start)
+	sched := dryRun(cfg, templates, rttClient, tflClient, sim, logger)
+	runDay(sched, sim, start)
+	return nil
+}
+
+// dryRun creates a scheduler on a simulated clock whose alerts are printed
+// instead of sent and not recorded in the journey history.
+func dryRun(cfg *config.Config, templates notify.Templates, rttClient *rtt.Client, tflClient *tfl.Client, sim *clock.Fake, logger *logrus.Logger) *scheduler.Scheduler {
+	bus := events.NewBus()
+	bus.Subscribe(notify.NewNotifier(notify.NewWriter(os.Stdout, sim), templates, logger).Handle)
+	publisher := outbox.NewDirect(bus.Publish)
+
+	trainMonitor := monitor.NewTrainMonitor(rttClient, publisher, nil, sim, logger)
+	tubeMonitor := monitor.NewTubeMonitor(tflClient, publisher, nil, sim, logger)
+	return scheduler.NewScheduler(cfg, trainMonitor, tubeMonitor, sim, logger)
+}
+
+// runDay runs the scheduler through the day beginning at start. Running the
+// clock to the last minute of the day runs each task as it falls due, without
+// waiting.
+func runDay(sched *scheduler.Scheduler, sim *clock.Fake, start time.Time) {
+	sim.Set(start)
+	sched.Start(context.Background())
+	sim.Set(start.AddDate(0, 0, 1).Add(-time.Minute))
+	sched.Stop()
+}
+
+// clockHook stamps log entries with the simulated time.
+type clockHook struct {
+	clock clock.Clock
+}
+
+func (clockHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h clockHook) Fire(entry *logrus.Entry) error {
+	entry.Time = h.clock.Now()
+	return nil
+}
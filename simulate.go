@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/api/fixture"
+	"github.com/danpilch/trainpal/internal/api/rtt"
+	"github.com/danpilch/trainpal/internal/api/tfl"
+	"github.com/danpilch/trainpal/internal/clock"
+	"github.com/danpilch/trainpal/internal/config"
+	"github.com/danpilch/trainpal/internal/history"
+	"github.com/danpilch/trainpal/internal/notifiers"
+	"github.com/danpilch/trainpal/internal/scheduler"
+)
+
+type SimulateCmd struct {
+	Date     string `help:"Day to simulate (YYYY-MM-DD), default today"`
+	Fixtures string `help:"Run the day against responses recorded with run --record, printing the alerts" type:"existingdir"`
+	Verbose  bool   `help:"Show the scheduler and monitor logs, not just warnings"`
+}
+
+// repeatUntil describes when each repeating task stops.
+var repeatUntil = map[scheduler.TaskType]string{
+	scheduler.TaskPlatformCheck:     "until departure",
+	scheduler.TaskDepartureCheck:    "until departed",
+	scheduler.TaskConnectionCheck:   "until the connection departs",
+	scheduler.TaskArrivalCheck:      "until arrived",
+	scheduler.TaskArrivalDelayCheck: "until arrived",
+}
+
+// Run prints the tasks the scheduler would run on a day and, given recorded
+// responses, runs them on a simulated clock.
+func (c *SimulateCmd) Run(globals *Globals, logger *logrus.Logger) error {
+	cfg, err := config.Load(globals.Config)
+	if err != nil {
+		return err
+	}
+	templates, err := notifiers.NewTemplates(cfg.Templates)
+	if err != nil {
+		return err
+	}
+
+	day := time.Now()
+	if c.Date != "" {
+		day, err = time.ParseInLocation(history.DateFormat, c.Date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --date: %w", err)
+		}
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	sim := clock.NewFake(start)
+
+	logger.AddHook(clockHook{sim})
+	if !c.Verbose {
+		logger.SetLevel(logrus.WarnLevel)
+	}
+
+	// The tube status summary is timed from the train's expected arrival, so
+	// working out the timeline asks RTT (or the recordings) once per journey
+	rttClient := rtt.NewClient(os.Getenv("RTT_URL"), os.Getenv("RTT_USERNAME"), os.Getenv("RTT_PASSWORD"))
+	tflClient := tfl.NewClient()
+	if c.Fixtures != "" {
+		replayer, err := fixture.Load(c.Fixtures, sim)
+		if err != nil {
+			return err
+		}
+		rttClient.SetTransport(replayer)
+		tflClient.SetTransport(replayer)
+	}
+
+	sched := dryRun(cfg, templates, rttClient, tflClient, sim, logger)
+	sched.SetupDailyTasks()
+	tasks := sched.Tasks()
+	printTimeline(tasks, start)
+	if c.Fixtures == "" || len(tasks) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	runDay(sched, sim, start)
+	return nil
+}
+
+func printTimeline(tasks []scheduler.Task, day time.Time) {
+	if len(tasks) == 0 {
+		fmt.Printf("No tasks scheduled for %s.\n", day.Format("Monday 2 January 2006"))
+		return
+	}
+	fmt.Printf("%d tasks scheduled for %s:\n\n", len(tasks), day.Format("Monday 2 January 2006"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, task := range tasks {
+		journey, leg := "-", "Northern line"
+		if task.Journey != nil {
+			journey = task.Journey.Name
+			leg = task.Leg.From + " -> " + task.Leg.To
+		}
+		if task.Type == scheduler.TaskConnectionCheck {
+			leg = task.Feeder.From + " -> " + task.Feeder.To + " -> " + task.Leg.To
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s", task.Time.Format("15:04"), task.Type, journey, leg)
+		if task.Repeating {
+			fmt.Fprintf(w, "\tevery %s %s", formatEvery(task.Every), repeatUntil[task.Type])
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// formatEvery formats an interval briefly, as 2m rather than 2m0s.
+func formatEvery(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
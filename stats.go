@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/danpilch/trainpal/internal/history"
+	"github.com/danpilch/trainpal/internal/stats"
+)
+
+type StatsCmd struct {
+	From         string   `help:"First date to include (YYYY-MM-DD), default 30 days ago"`
+	To           string   `help:"Last date to include (YYYY-MM-DD), default today"`
+	Journey      []string `help:"Only report these journeys"`
+	OnTimeWithin int      `help:"Minutes late a service can be and still count as on time" default:"5"`
+	Format       string   `help:"Output format" enum:"table,json" default:"table"`
+	Claims       bool     `help:"List the Delay Repay claims recorded instead of punctuality"`
+}
+
+func (c *StatsCmd) Run(globals *Globals, logger *logrus.Logger) error {
+	to := time.Now()
+	if c.To != "" {
+		t, err := time.ParseInLocation(history.DateFormat, c.To, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --to date: %w", err)
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -30)
+	if c.From != "" {
+		t, err := time.ParseInLocation(history.DateFormat, c.From, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --from date: %w", err)
+		}
+		from = t
+	}
+	if from.After(to) {
+		return fmt.Errorf("--from %s is after --to %s", from.Format(history.DateFormat), to.Format(history.DateFormat))
+	}
+
+	if _, err := os.Stat(globals.History); err != nil {
+		return fmt.Errorf("reading journey history: %w", err)
+	}
+	store, err := history.Open(globals.History, logger)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	wanted := make(map[string]bool, len(c.Journey))
+	for _, j := range c.Journey {
+		wanted[j] = true
+	}
+
+	if c.Claims {
+		claims := store.DelayRepayClaims(from, to)
+		if len(wanted) > 0 {
+			filtered := claims[:0]
+			for _, claim := range claims {
+				if wanted[claim.Journey] {
+					filtered = append(filtered, claim)
+				}
+			}
+			claims = filtered
+		}
+		if c.Format == "json" {
+			return stats.WriteClaimsJSON(os.Stdout, claims)
+		}
+		return stats.WriteClaimsTable(os.Stdout, claims)
+	}
+
+	services := store.Services(from, to)
+	if len(wanted) > 0 {
+		filtered := services[:0]
+		for _, svc := range services {
+			if wanted[svc.Journey] {
+				filtered = append(filtered, svc)
+			}
+		}
+		services = filtered
+	}
+
+	summary := stats.Compute(services, c.OnTimeWithin)
+	if c.Format == "json" {
+		return stats.WriteJSON(os.Stdout, summary)
+	}
+	return stats.WriteTable(os.Stdout, summary)
+}